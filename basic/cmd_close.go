@@ -18,6 +18,7 @@ func sendClose(o *Object) {
 			}
 			return true
 		})
+		p.safeCloseHooks()
 		p.safeStop()
 		return nil
 	}))
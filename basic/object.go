@@ -50,6 +50,12 @@ type Object struct {
 	ticker *time.Ticker
 	// sinker .
 	sinker Sinker
+	// traceID 正在处理的消息的追踪ID
+	traceID string
+	// closeHooks 节点开始关闭时执行的方法
+	closeHooks []func(o *Object)
+	// hooksDone 是否已经执行过 closeHooks
+	hooksDone bool
 }
 
 // NewObject 创建节点
@@ -109,6 +115,22 @@ func (o *Object) safeTick() {
 	}
 }
 
+// safeCloseHooks 执行所有关闭时的方法，每个节点只执行一次
+func (o *Object) safeCloseHooks() {
+	o.Lock()
+	hooks := o.closeHooks
+	o.closeHooks = nil
+	o.hooksDone = true
+	o.Unlock()
+
+	for _, f := range hooks {
+		func() {
+			defer utils.DumpStackIfPanic("Object::CloseHook")
+			f(o)
+		}()
+	}
+}
+
 func (o *Object) safeStop() {
 	defer utils.DumpStackIfPanic("Object::OnStop")
 
@@ -232,6 +254,38 @@ func (o *Object) AddChild(c *Object) {
 	sendAddChild(o, c)
 }
 
+// TraceID 获取正在处理的消息的追踪ID
+func (o *Object) TraceID() string {
+	o.Lock()
+	defer o.Unlock()
+	return o.traceID
+}
+
+// SetTraceID 设置正在处理的消息的追踪ID，返回原来的追踪ID
+// 一般在处理消息前设置，处理结束后恢复，之后创建的任务会继承这个追踪ID
+func (o *Object) SetTraceID(id string) string {
+	o.Lock()
+	defer o.Unlock()
+	prev := o.traceID
+	o.traceID = id
+	return prev
+}
+
+// AddCloseHook 添加节点开始关闭时执行的方法，在节点的协程中执行，先于 Sinker.OnStop
+// 节点已经开始关闭时不再添加，返回false
+func (o *Object) AddCloseHook(f func(o *Object)) bool {
+	if f == nil {
+		return false
+	}
+	o.Lock()
+	defer o.Unlock()
+	if o.hooksDone || o.Closed {
+		return false
+	}
+	o.closeHooks = append(o.closeHooks, f)
+	return true
+}
+
 // IsClosed 是否已经关闭
 func (o *Object) IsClosed() bool {
 	o.Lock()
@@ -5,6 +5,7 @@ go 1.15
 require (
 	github.com/cihub/seelog v0.0.0-20170130134532-f561c5e57575
 	github.com/golang/protobuf v1.4.3
+	github.com/gorilla/websocket v1.4.2
 	github.com/stathat/consistent v1.0.0
 	stathat.com/c/consistent v1.0.0 // indirect
 )
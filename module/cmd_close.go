@@ -0,0 +1,16 @@
+package module
+
+import (
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/log"
+)
+
+// sendClose 在模块自己的节点中关闭模块
+func sendClose(mod *module) {
+	mod.obj.Send(basic.CommandWrapper(func(o *basic.Object) error {
+		log.Infof("module [%16s] close...", mod.mi.Name())
+		mod.safeClose()
+		log.Infof("module [%16s] close[ok]", mod.mi.Name())
+		return nil
+	}))
+}
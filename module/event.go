@@ -0,0 +1,128 @@
+package module
+
+import (
+	"sync"
+	"time"
+
+	"github.com/skeletongo/core/utils"
+)
+
+// EventType 模块生命周期事件类型
+type EventType int
+
+const (
+	EventRegistered   EventType = iota // 模块已注册
+	EventInitStart                     // 开始初始化
+	EventInitFinish                    // 初始化完成
+	EventFirstUpdate                   // 第一次执行 Update
+	EventCloseRequest                  // 开始关闭
+	EventClosed                        // 已关闭
+	EventMax
+)
+
+var eventNames = [EventMax]string{
+	"registered",
+	"init start",
+	"init finish",
+	"first update",
+	"close request",
+	"closed",
+}
+
+func (t EventType) String() string {
+	if t < 0 || t >= EventMax {
+		return "unknown"
+	}
+	return eventNames[t]
+}
+
+// Event 模块生命周期事件
+type Event struct {
+	Type EventType // 事件类型
+	Name string    // 模块名称
+	Time time.Time // 事件发生时间
+}
+
+// Hook 模块生命周期事件处理方法
+// 在触发事件的协程中执行，一般是模块节点，独立执行的模块的 EventFirstUpdate 在模块自己的节点中执行
+type Hook interface {
+	OnEvent(e *Event)
+}
+
+type HookWrapper func(e *Event)
+
+func (hw HookWrapper) OnEvent(e *Event) {
+	hw(e)
+}
+
+type hook struct {
+	id   int
+	mask uint
+	h    Hook
+}
+
+// hooks 所有事件处理方法
+var hooks struct {
+	sync.RWMutex
+	i    int
+	list []*hook
+}
+
+// Subscribe 订阅模块生命周期事件
+// h 事件处理方法
+// types 订阅的事件类型，为空时订阅所有事件
+// 返回订阅id,用来取消订阅
+func Subscribe(h Hook, types ...EventType) int {
+	if h == nil {
+		return 0
+	}
+	var mask uint
+	for _, v := range types {
+		mask |= 1 << uint(v)
+	}
+	if mask == 0 {
+		mask = 1<<uint(EventMax) - 1
+	}
+
+	hooks.Lock()
+	defer hooks.Unlock()
+	hooks.i++
+	hooks.list = append(hooks.list, &hook{id: hooks.i, mask: mask, h: h})
+	return hooks.i
+}
+
+// Unsubscribe 取消订阅
+// id 订阅id
+func Unsubscribe(id int) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	for i, v := range hooks.list {
+		if v.id == id {
+			hooks.list = append(hooks.list[:i:i], hooks.list[i+1:]...)
+			return
+		}
+	}
+}
+
+// fire 触发事件
+func fire(t EventType, name string) {
+	hooks.RLock()
+	list := hooks.list
+	hooks.RUnlock()
+
+	e := &Event{
+		Type: t,
+		Name: name,
+		Time: time.Now(),
+	}
+	for _, v := range list {
+		if v.mask&(1<<uint(t)) != 0 {
+			safeEvent(v.h, e)
+		}
+	}
+}
+
+func safeEvent(h Hook, e *Event) {
+	defer utils.DumpStackIfPanic("Module.Hook.OnEvent")
+	h.OnEvent(e)
+}
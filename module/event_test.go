@@ -0,0 +1,61 @@
+package module
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	setup(t)
+	ch := make(chan *Event, 100)
+	id := Subscribe(HookWrapper(func(e *Event) { ch <- e }))
+	defer Unsubscribe(id)
+	// 只订阅关闭事件
+	closed := make(chan *Event, 100)
+	id2 := Subscribe(HookWrapper(func(e *Event) { closed <- e }), EventClosed)
+	defer Unsubscribe(id2)
+
+	Register(&testModule{name: "b"}, 0, 2)
+	Register(&testModule{name: "a"}, 0, 1)
+	Start()
+	var events []string
+	record := func(typ EventType, n int) {
+		for n > 0 {
+			select {
+			case e := <-ch:
+				events = append(events, e.Type.String()+" "+e.Name)
+				if e.Type == typ {
+					n--
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("wait event timeout:", typ)
+			}
+		}
+	}
+	record(EventFirstUpdate, 2)
+	Stop()
+	record(EventClosed, 2)
+
+	// 注册按调用顺序，初始化和更新按优先级，关闭按优先级倒序
+	want := []string{
+		"registered b",
+		"registered a",
+		"init start a",
+		"init finish a",
+		"init start b",
+		"init finish b",
+		"first update a",
+		"first update b",
+		"close request b",
+		"close request a",
+		"closed b",
+		"closed a",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events %v, want %v", events, want)
+	}
+	if len(closed) != 2 {
+		t.Error("closed events", len(closed))
+	}
+}
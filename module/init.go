@@ -5,11 +5,21 @@ import (
 
 	"github.com/skeletongo/core/basic"
 	"github.com/skeletongo/core/pkg"
+	"github.com/skeletongo/core/utils"
 )
 
 // Obj 模块功能节点
 var Obj *basic.Object
 
+// objIDs 模块节点的子节点ID
+var objIDs utils.IdGen
+
+// NextObjID 分配模块节点的子节点ID
+// 在 Obj 下创建子节点时都应该使用这个方法分配ID，避免和独立执行的模块节点冲突
+func NextObjID() int {
+	return objIDs.NextId()
+}
+
 // Config 节点配置
 var Config = new(Configuration)
 
@@ -30,28 +30,62 @@ type module struct {
 	interval time.Duration
 	// 优先级，越小优先级越高
 	priority int
+	// dedicated 是否在独立节点中执行 Update
+	dedicated bool
+	// obj 独立执行 Update 的节点，dedicated 为 true 时有效
+	obj *basic.Object
+	// budget 单次 Update 可用的时长，超出时输出警告
+	budget time.Duration
+	// prof 耗时统计
+	prof *profile
+	// updated 是否已经执行过 Update
+	updated bool
 	// implement Module
 	mi Module
 }
 
 func (m *module) safeInit() {
 	defer utils.DumpStackIfPanic("Module.safeInit")
+	defer m.prof.addInit(time.Now())
 	m.mi.Init()
 }
 
 func (m *module) safeUpdate(t time.Time) {
 	defer utils.DumpStackIfPanic("Module.safeUpdate")
-	if m.interval == 0 || t.Sub(m.lastTime) >= m.interval {
+	// 独立节点的 tick 间隔就是 interval，不需要再判定
+	if m.dedicated || m.interval == 0 || t.Sub(m.lastTime) >= m.interval {
 		m.lastTime = t
-		m.mi.Update()
+		m.update()
+	}
+}
+
+func (m *module) update() {
+	if !m.updated {
+		m.updated = true
+		fire(EventFirstUpdate, m.mi.Name())
 	}
+	defer m.prof.addUpdate(time.Now(), m.budget)
+	m.mi.Update()
 }
 
 func (m *module) safeClose() {
 	defer utils.DumpStackIfPanic("Module.safeClose")
+	defer m.prof.addClose(time.Now())
 	m.mi.Close()
 }
 
+// start 创建独立节点执行 Update
+func (m *module) start() {
+	opt := &basic.Options{Interval: m.interval}
+	if opt.Interval <= 0 {
+		opt.Interval = Obj.Opt.Interval
+	}
+	m.budget = opt.Interval
+	m.obj = basic.NewObject(NextObjID(), m.mi.Name(), opt, &dedicatedSink{mod: m})
+	m.obj.Run()
+	Obj.AddChild(m.obj)
+}
+
 // 模块管理器状态
 const (
 	StateInvalid = iota // 停止
@@ -94,18 +128,51 @@ func (m *moduleMgr) init() {
 	for e := m.mods.Front(); e != nil; e = e.Next() {
 		mod := e.Value.(*module)
 		log.Infof("module [%16s] init...", mod.mi.Name())
+		fire(EventInitStart, mod.mi.Name())
 		mod.safeInit()
 		log.Infof("module [%16s] init[ok]", mod.mi.Name())
+		fire(EventInitFinish, mod.mi.Name())
 	}
 	log.Infof("module init[ok]")
 
+	// 共享节点中的模块平分一次 tick 的时长，独立执行的模块创建自己的节点
+	for e := m.mods.Front(); e != nil; e = e.Next() {
+		if mod := e.Value.(*module); mod.dedicated {
+			mod.start()
+		}
+	}
+	m.setBudget()
+
 	m.state = StateUpdate
 }
 
+// setBudget 计算共享节点中每个模块单次 Update 可用的时长
+func (m *moduleMgr) setBudget() {
+	var n int64
+	for e := m.mods.Front(); e != nil; e = e.Next() {
+		if !e.Value.(*module).dedicated {
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	budget := Obj.Opt.Interval / time.Duration(n)
+	for e := m.mods.Front(); e != nil; e = e.Next() {
+		if mod := e.Value.(*module); !mod.dedicated {
+			mod.budget = budget
+		}
+	}
+}
+
 func (m *moduleMgr) update() {
 	nowTime := time.Now()
 	for e := m.mods.Front(); e != nil; e = e.Next() {
-		e.Value.(*module).safeUpdate(nowTime)
+		mod := e.Value.(*module)
+		if mod.dedicated {
+			continue
+		}
+		mod.safeUpdate(nowTime)
 	}
 }
 
@@ -119,6 +186,12 @@ func (m *moduleMgr) close() {
 	log.Infof("module close...")
 	for e := m.mods.Back(); e != nil; e = e.Prev() {
 		mod := e.Value.(*module)
+		fire(EventCloseRequest, mod.mi.Name())
+		if mod.dedicated && mod.obj != nil {
+			// 在模块自己的节点中关闭，和 Update 保持在同一个协程
+			sendClose(mod)
+			continue
+		}
 		log.Infof("module [%16s] close...", mod.mi.Name())
 		mod.safeClose()
 		log.Infof("module [%16s] close[ok]", mod.mi.Name())
@@ -135,8 +208,12 @@ func (m *moduleMgr) closing() {
 		select {
 		case name := <-m.modSign:
 			for e := m.mods.Front(); e != nil; e = e.Next() {
-				if e.Value.(*module).mi.Name() == name {
+				if mod := e.Value.(*module); mod.mi.Name() == name {
 					m.mods.Remove(e)
+					if mod.obj != nil {
+						mod.obj.Close()
+					}
+					fire(EventClosed, name)
 					break
 				}
 			}
@@ -184,12 +261,28 @@ func Closed(m Module) {
 // interval 间隔时长；如果值为0表示以最短间隔时间执行update,取值范围大于等于0
 // priority 优先级；值越小越优先处理
 func Register(m Module, interval time.Duration, priority int) {
+	register(m, interval, priority, false)
+}
+
+// RegisterDedicated 注册在独立节点中执行 Update 的模块
+// Init 仍然在模块节点中按优先级顺序执行，Update 和 Close 在模块自己的节点中执行，不会拖慢其它模块
+// interval 间隔时长；如果值为0表示以模块节点的间隔时间执行update,取值范围大于等于0
+// priority 优先级；值越小越优先处理
+func RegisterDedicated(m Module, interval time.Duration, priority int) {
+	register(m, interval, priority, true)
+}
+
+func register(m Module, interval time.Duration, priority int, dedicated bool) {
 	mod := &module{
-		lastTime: time.Now(),
-		interval: interval,
-		priority: priority,
-		mi:       m,
+		lastTime:  time.Now(),
+		interval:  interval,
+		priority:  priority,
+		dedicated: dedicated,
+		prof:      newProfile(m.Name(), dedicated),
+		mi:        m,
 	}
+	profiles.Store(m.Name(), mod.prof)
+	defer fire(EventRegistered, m.Name())
 	for e := defaultModuleMgr.mods.Front(); e != nil; e = e.Next() {
 		if me, ok := e.Value.(*module); ok {
 			if priority < me.priority {
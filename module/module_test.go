@@ -0,0 +1,114 @@
+package module
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+)
+
+type testModule struct {
+	name   string
+	sleep  time.Duration
+	update int32
+}
+
+func (m *testModule) Name() string {
+	return m.name
+}
+
+func (m *testModule) Init() {
+}
+
+func (m *testModule) Update() {
+	atomic.AddInt32(&m.update, 1)
+	time.Sleep(m.sleep)
+}
+
+func (m *testModule) Close() {
+	Closed(m)
+}
+
+// setup 重置模块管理器并创建模块节点
+func setup(t *testing.T) {
+	defaultModuleMgr = newModuleMgr()
+	Obj = basic.NewObject(basic.ModuleID, "module", &basic.Options{Interval: 10 * time.Millisecond}, new(sink))
+	Obj.Run()
+	t.Cleanup(Obj.Close)
+}
+
+// waitEvent 等待所有模块都触发事件
+func waitEvent(t *testing.T, ch chan *Event, typ EventType, n int) {
+	t.Helper()
+	for n > 0 {
+		select {
+		case e := <-ch:
+			if e.Type == typ {
+				n--
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("wait event timeout:", typ)
+		}
+	}
+}
+
+func getModule(name string) *module {
+	for e := defaultModuleMgr.mods.Front(); e != nil; e = e.Next() {
+		if mod := e.Value.(*module); mod.mi.Name() == name {
+			return mod
+		}
+	}
+	return nil
+}
+
+func TestRegisterDedicated(t *testing.T) {
+	setup(t)
+	ch := make(chan *Event, 100)
+	id := Subscribe(HookWrapper(func(e *Event) { ch <- e }), EventFirstUpdate, EventClosed)
+	defer Unsubscribe(id)
+
+	slow := &testModule{name: "slow", sleep: 30 * time.Millisecond}
+	Register(slow, 0, 1)
+	Register(&testModule{name: "fast"}, 0, 2)
+	ded := &testModule{name: "ded"}
+	RegisterDedicated(ded, 0, 3)
+	RegisterDedicated(&testModule{name: "ded2"}, 20*time.Millisecond, 0)
+	Start()
+	waitEvent(t, ch, EventFirstUpdate, 4)
+
+	// 共享节点中的模块平分一次 tick 的时长，独立执行的模块使用自己的间隔
+	for name, want := range map[string]time.Duration{
+		"slow": 5 * time.Millisecond,
+		"fast": 5 * time.Millisecond,
+		"ded":  10 * time.Millisecond,
+		"ded2": 20 * time.Millisecond,
+	} {
+		if mod := getModule(name); mod.budget != want {
+			t.Errorf("%v budget %v, want %v", name, mod.budget, want)
+		}
+	}
+
+	// 独立节点的ID不依赖注册顺序，也不会和其它子节点冲突
+	id1, id2 := getModule("ded").obj.ID, getModule("ded2").obj.ID
+	if id1 == id2 || id1 == NextObjID() || id2 == NextObjID() {
+		t.Error("dedicated object id conflict", id1, id2)
+	}
+
+	// 慢模块不会拖慢独立执行的模块
+	time.Sleep(300 * time.Millisecond)
+	s, _ := GetStat("slow")
+	d, _ := GetStat("ded")
+	if !d.Dedicated || s.Dedicated {
+		t.Error("dedicated flag")
+	}
+	if d.UpdateNum < 2*s.UpdateNum {
+		t.Error("dedicated module blocked", d.UpdateNum, s.UpdateNum)
+	}
+	if s.SlowNum == 0 {
+		t.Error("slow update not counted")
+	}
+
+	Stop()
+	waitEvent(t, ch, EventClosed, 4)
+}
@@ -0,0 +1,120 @@
+package module
+
+import (
+	"sync"
+	"time"
+
+	"github.com/skeletongo/core/log"
+)
+
+// statWindow 滚动统计的采样次数
+const statWindow = 100
+
+// Stat 模块耗时统计
+type Stat struct {
+	Name        string        // 模块名称
+	Dedicated   bool          // 是否在独立节点中执行 Update
+	Init        time.Duration // Init 耗时
+	Close       time.Duration // Close 耗时
+	UpdateNum   uint64        // Update 执行次数
+	UpdateTotal time.Duration // Update 总耗时
+	UpdateLast  time.Duration // 最近一次 Update 耗时
+	UpdateAvg   time.Duration // 最近 statWindow 次 Update 的平均耗时
+	UpdateMax   time.Duration // 最近 statWindow 次 Update 的最大耗时
+	SlowNum     uint64        // Update 耗时超出预算的次数
+}
+
+// profile 模块耗时记录
+type profile struct {
+	sync.Mutex
+	stat Stat
+	// samples 最近 statWindow 次 Update 耗时
+	samples [statWindow]time.Duration
+	// n 采样数量
+	n int
+	// pos 下一个采样写入位置
+	pos int
+}
+
+func newProfile(name string, dedicated bool) *profile {
+	p := new(profile)
+	p.stat.Name = name
+	p.stat.Dedicated = dedicated
+	return p
+}
+
+func (p *profile) addInit(start time.Time) {
+	p.Lock()
+	p.stat.Init = time.Since(start)
+	p.Unlock()
+}
+
+func (p *profile) addClose(start time.Time) {
+	p.Lock()
+	p.stat.Close = time.Since(start)
+	p.Unlock()
+}
+
+// addUpdate 记录一次 Update 耗时
+// budget 本次 Update 可用的时长，超出时输出警告；为0时不检查
+func (p *profile) addUpdate(start time.Time, budget time.Duration) {
+	cost := time.Since(start)
+
+	p.Lock()
+	p.stat.UpdateNum++
+	p.stat.UpdateTotal += cost
+	p.stat.UpdateLast = cost
+	p.samples[p.pos] = cost
+	p.pos = (p.pos + 1) % statWindow
+	if p.n < statWindow {
+		p.n++
+	}
+	slow := budget > 0 && cost > budget
+	if slow {
+		p.stat.SlowNum++
+	}
+	p.Unlock()
+
+	if slow {
+		_ = log.Warnf("module [%16s] update cost %v, exceed %v", p.stat.Name, cost, budget)
+	}
+}
+
+func (p *profile) get() Stat {
+	p.Lock()
+	defer p.Unlock()
+	s := p.stat
+	if p.n > 0 {
+		var sum time.Duration
+		for i := 0; i < p.n; i++ {
+			sum += p.samples[i]
+			if p.samples[i] > s.UpdateMax {
+				s.UpdateMax = p.samples[i]
+			}
+		}
+		s.UpdateAvg = sum / time.Duration(p.n)
+	}
+	return s
+}
+
+// profiles 所有模块的耗时记录; key:模块名称,value:*profile
+var profiles = new(sync.Map)
+
+// GetStats 获取所有模块的耗时统计
+func GetStats() map[string]Stat {
+	stats := make(map[string]Stat)
+	profiles.Range(func(key, value interface{}) bool {
+		stats[key.(string)] = value.(*profile).get()
+		return true
+	})
+	return stats
+}
+
+// GetStat 获取模块的耗时统计
+func GetStat(name string) (Stat, bool) {
+	v, ok := profiles.Load(name)
+	if !ok {
+		return Stat{}, false
+	}
+	return v.(*profile).get(), true
+}
@@ -1,5 +1,7 @@
 package module
 
+import "time"
+
 type sink struct {
 }
 
@@ -13,4 +15,17 @@ func (s *sink) OnTick() {
 func (s *sink) OnStop() {
 }
 
+// dedicatedSink 独立执行 Update 的模块节点
+type dedicatedSink struct {
+	mod *module
+}
+
+func (s *dedicatedSink) OnStart() {
+}
 
+func (s *dedicatedSink) OnTick() {
+	s.mod.safeUpdate(time.Now())
+}
+
+func (s *dedicatedSink) OnStop() {
+}
@@ -8,9 +8,19 @@ type action struct {
 	s     ISession
 	msgID int
 	msg   interface{}
+	f     func() // 会话事件，不为空时不处理消息
 }
 
 func (a *action) do() {
+	if a.f != nil {
+		a.f()
+		a.f = nil
+		return
+	}
+	// 过滤器拒绝的会话已经关闭，丢弃读协程之前放入队列的消息
+	if s, ok := a.s.(*Session); ok && s.rejected {
+		return
+	}
 	h := GetHandler(a.msgID)
 	if h == nil {
 		log.Errorf("%v not register handler", a.msgID)
@@ -0,0 +1,420 @@
+package network
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrMsgTooLarge = errors.New("message too large ")
+	ErrDeadLink    = errors.New("udp link dead ")
+)
+
+// arq 报文命令
+const (
+	arqCmdPush       = iota + 1 // 可靠数据
+	arqCmdAck                   // 确认
+	arqCmdUnreliable            // 不可靠数据
+	arqCmdPing                  // 保活
+	arqCmdFin                   // 关闭
+	arqCmdSyn                   // 握手请求，携带 cookie
+	arqCmdSynAck                // 握手回复，携带 cookie
+)
+
+// arqHeadLen 报文头长度 conv(4) cmd(1) frg(1) wnd(2) sn(4) una(4)
+const arqHeadLen = 16
+
+// arqMaxFrg 一个消息最多分成的片段数量，同时不能超过接收窗口，否则接收方无法组成消息
+const arqMaxFrg = 256
+
+// segment 报文
+type segment struct {
+	cmd  uint8
+	frg  uint8  // 消息剩余的片段数量，最后一个片段为0
+	wnd  uint16 // 发送方剩余的接收窗口
+	sn   uint32 // 序号
+	una  uint32 // 发送方下一个等待接收的序号，之前的都已经收到
+	data []byte
+
+	// 以下字段只用于发送
+	sentAt   time.Time     // 第一次发送的时间
+	resendAt time.Time     // 超时重传的时间
+	rto      time.Duration // 超时重传时长
+	fastack  int           // 后续报文的确认次数
+	xmit     int           // 发送次数
+}
+
+func (s *segment) encode(conv uint32) []byte {
+	b := make([]byte, arqHeadLen+len(s.data))
+	defaultEndian.PutUint32(b, conv)
+	b[4] = s.cmd
+	b[5] = s.frg
+	defaultEndian.PutUint16(b[6:], s.wnd)
+	defaultEndian.PutUint32(b[8:], s.sn)
+	defaultEndian.PutUint32(b[12:], s.una)
+	copy(b[arqHeadLen:], s.data)
+	return b
+}
+
+// decodeSegment 解析报文头，data 引用 b 的数据
+func decodeSegment(b []byte) (conv uint32, s *segment, ok bool) {
+	if len(b) < arqHeadLen {
+		return 0, nil, false
+	}
+	s = &segment{
+		cmd:  b[4],
+		frg:  b[5],
+		wnd:  defaultEndian.Uint16(b[6:]),
+		sn:   defaultEndian.Uint32(b[8:]),
+		una:  defaultEndian.Uint32(b[12:]),
+		data: b[arqHeadLen:],
+	}
+	return defaultEndian.Uint32(b), s, true
+}
+
+// snDiff 序号差，处理序号回绕
+func snDiff(a, b uint32) int32 {
+	return int32(a - b)
+}
+
+// arqConfig 可靠传输配置
+type arqConfig struct {
+	mtu        int
+	sndWnd     int
+	rcvWnd     int
+	interval   time.Duration
+	minRTO     time.Duration
+	fastResend int
+	deadLink   int
+	keepAlive  time.Duration
+}
+
+func newArqConfig(sc *SessionConfig) *arqConfig {
+	keepAlive := sc.KeepAlivePeriod
+	if keepAlive <= 0 {
+		// 保证对方在读超时前能收到报文
+		keepAlive = sc.ReadTimeout / 3
+	}
+	return &arqConfig{
+		mtu:        sc.MTU,
+		sndWnd:     sc.SndWnd,
+		rcvWnd:     sc.RcvWnd,
+		interval:   sc.UDPInterval,
+		minRTO:     sc.MinRTO,
+		fastResend: sc.FastResend,
+		deadLink:   sc.DeadLink,
+		keepAlive:  keepAlive,
+	}
+}
+
+// arq 类似 KCP 的可靠有序传输，使用选择重传、超时重传和快速重传，不包含拥塞控制
+// 不是协程安全的，由 UDPConn 加锁调用
+type arq struct {
+	conv   uint32
+	cfg    *arqConfig
+	mss    int
+	output func(b []byte)
+
+	sndUna uint32 // 最早没有确认的序号
+	sndNxt uint32 // 下一个发送的序号
+	rcvNxt uint32 // 下一个等待接收的序号
+	rmtWnd int    // 对方剩余的接收窗口
+
+	sndQueue []*segment          // 等待发送窗口的报文
+	sndBuf   []*segment          // 已经发送还没有确认的报文，按序号排序
+	rcvBuf   map[uint32]*segment // 收到的乱序报文，以及接收队列满时等待的报文
+	rcvQueue []*segment          // 按顺序收到还没有组成消息的报文，最多 rcvWnd 个
+	urcv     [][]byte            // 收到的不可靠消息
+
+	srtt, rttvar, rto time.Duration
+
+	lastSend time.Time
+	lastRecv time.Time
+	dead     bool
+	fin      bool // 对方已经关闭
+}
+
+func newArq(conv uint32, cfg *arqConfig, output func(b []byte)) *arq {
+	now := time.Now()
+	return &arq{
+		conv:     conv,
+		cfg:      cfg,
+		mss:      cfg.mtu - arqHeadLen,
+		output:   output,
+		rmtWnd:   cfg.rcvWnd,
+		rcvBuf:   make(map[uint32]*segment),
+		rto:      cfg.minRTO * 2,
+		lastSend: now,
+		lastRecv: now,
+	}
+}
+
+// wnd 剩余的接收窗口，按接收队列计算
+func (a *arq) wnd() uint16 {
+	n := a.cfg.rcvWnd - len(a.rcvQueue)
+	if n < 0 {
+		return 0
+	}
+	return uint16(n)
+}
+
+// maxFrg 一个消息最多分成的片段数量
+func (a *arq) maxFrg() int {
+	if a.cfg.rcvWnd < arqMaxFrg {
+		return a.cfg.rcvWnd
+	}
+	return arqMaxFrg
+}
+
+// moveRcv 把按顺序收到的报文移到接收队列，队列满时留在 rcvBuf 中，rcvNxt 不再增加
+// 片段数量和正在组成的消息不一致时链接失效
+func (a *arq) moveRcv() {
+	for len(a.rcvQueue) < a.cfg.rcvWnd {
+		v, ok := a.rcvBuf[a.rcvNxt]
+		if !ok {
+			return
+		}
+		if n := len(a.rcvQueue); n > 0 && a.rcvQueue[n-1].frg != 0 && v.frg != a.rcvQueue[n-1].frg-1 {
+			a.dead = true
+			return
+		}
+		delete(a.rcvBuf, a.rcvNxt)
+		a.rcvQueue = append(a.rcvQueue, v)
+		a.rcvNxt++
+	}
+}
+
+func (a *arq) emit(s *segment, now time.Time) {
+	s.wnd = a.wnd()
+	s.una = a.rcvNxt
+	a.output(s.encode(a.conv))
+	a.lastSend = now
+}
+
+// send 可靠发送消息，超过 mss 时分成多个片段
+func (a *arq) send(msg []byte) error {
+	n := (len(msg) + a.mss - 1) / a.mss
+	if n == 0 {
+		n = 1
+	}
+	if n > a.maxFrg() {
+		return ErrMsgTooLarge
+	}
+	for i := 0; i < n; i++ {
+		end := (i + 1) * a.mss
+		if end > len(msg) {
+			end = len(msg)
+		}
+		data := make([]byte, end-i*a.mss)
+		copy(data, msg[i*a.mss:end])
+		a.sndQueue = append(a.sndQueue, &segment{
+			cmd:  arqCmdPush,
+			frg:  uint8(n - 1 - i),
+			data: data,
+		})
+	}
+	return nil
+}
+
+// sendUnreliable 不可靠发送，立即发送一次，不重传
+func (a *arq) sendUnreliable(msg []byte, now time.Time) error {
+	if len(msg) > a.mss {
+		return ErrMsgTooLarge
+	}
+	a.emit(&segment{cmd: arqCmdUnreliable, data: msg}, now)
+	return nil
+}
+
+// sendFin 通知对方关闭
+func (a *arq) sendFin(now time.Time) {
+	a.emit(&segment{cmd: arqCmdFin}, now)
+}
+
+// recv 获取一个完整的消息，优先返回不可靠消息
+func (a *arq) recv() ([]byte, bool) {
+	if len(a.urcv) > 0 {
+		msg := a.urcv[0]
+		a.urcv[0] = nil
+		a.urcv = a.urcv[1:]
+		return msg, true
+	}
+	for i, s := range a.rcvQueue {
+		if s.frg != 0 {
+			continue
+		}
+		size := 0
+		for _, v := range a.rcvQueue[:i+1] {
+			size += len(v.data)
+		}
+		msg := make([]byte, 0, size)
+		for _, v := range a.rcvQueue[:i+1] {
+			msg = append(msg, v.data...)
+		}
+		a.rcvQueue = append(a.rcvQueue[:0], a.rcvQueue[i+1:]...)
+		a.moveRcv()
+		return msg, true
+	}
+	return nil, false
+}
+
+// ackUna 删除 una 之前的已确认报文
+func (a *arq) ackUna(una uint32) {
+	i := 0
+	for ; i < len(a.sndBuf); i++ {
+		if snDiff(a.sndBuf[i].sn, una) >= 0 {
+			break
+		}
+	}
+	if i > 0 {
+		a.sndBuf = append(a.sndBuf[:0], a.sndBuf[i:]...)
+	}
+	a.shrink()
+}
+
+func (a *arq) shrink() {
+	if len(a.sndBuf) > 0 {
+		a.sndUna = a.sndBuf[0].sn
+	} else {
+		a.sndUna = a.sndNxt
+	}
+}
+
+// ackSn 确认一个报文，更新 RTT，之前的报文快速重传计数加一
+func (a *arq) ackSn(sn uint32, now time.Time) {
+	if snDiff(sn, a.sndUna) < 0 || snDiff(sn, a.sndNxt) >= 0 {
+		return
+	}
+	for i, s := range a.sndBuf {
+		if s.sn == sn {
+			if s.xmit == 1 {
+				a.updateRTT(now.Sub(s.sentAt))
+			}
+			a.sndBuf = append(a.sndBuf[:i], a.sndBuf[i+1:]...)
+			break
+		}
+		if snDiff(s.sn, sn) > 0 {
+			break
+		}
+		s.fastack++
+	}
+	a.shrink()
+}
+
+func (a *arq) updateRTT(rtt time.Duration) {
+	if a.srtt == 0 {
+		a.srtt = rtt
+		a.rttvar = rtt / 2
+	} else {
+		delta := rtt - a.srtt
+		if delta < 0 {
+			delta = -delta
+		}
+		a.rttvar = (3*a.rttvar + delta) / 4
+		a.srtt = (7*a.srtt + rtt) / 8
+	}
+	rto := 4 * a.rttvar
+	if rto < a.cfg.interval {
+		rto = a.cfg.interval
+	}
+	a.rto = a.srtt + rto
+	if a.rto < a.cfg.minRTO {
+		a.rto = a.cfg.minRTO
+	}
+	if max := 60 * time.Second; a.rto > max {
+		a.rto = max
+	}
+}
+
+// input 处理收到的报文
+func (a *arq) input(b []byte, now time.Time) {
+	conv, s, ok := decodeSegment(b)
+	if !ok || conv != a.conv || s.cmd == arqCmdSyn || s.cmd == arqCmdSynAck {
+		// 握手报文在建立链接前处理，重复的握手报文直接丢弃
+		return
+	}
+	a.lastRecv = now
+	a.rmtWnd = int(s.wnd)
+	a.ackUna(s.una)
+
+	switch s.cmd {
+	case arqCmdAck:
+		a.ackSn(s.sn, now)
+	case arqCmdPush:
+		if snDiff(s.sn, a.rcvNxt+uint32(a.cfg.rcvWnd)) >= 0 {
+			// 超出接收窗口
+			return
+		}
+		if int(s.frg) >= a.maxFrg() {
+			// 超出片段数量的消息无法组成
+			a.dead = true
+			return
+		}
+		if snDiff(s.sn, a.rcvNxt) >= 0 {
+			if _, exist := a.rcvBuf[s.sn]; !exist {
+				data := make([]byte, len(s.data))
+				copy(data, s.data)
+				s.data = data
+				a.rcvBuf[s.sn] = s
+			}
+			a.moveRcv()
+		}
+		// 重复的报文也要确认，对方可能没有收到之前的确认
+		a.emit(&segment{cmd: arqCmdAck, sn: s.sn}, now)
+	case arqCmdUnreliable:
+		if len(a.urcv) < a.cfg.rcvWnd {
+			data := make([]byte, len(s.data))
+			copy(data, s.data)
+			a.urcv = append(a.urcv, data)
+		}
+	case arqCmdFin:
+		a.fin = true
+	}
+}
+
+// flush 发送新报文，重传超时和需要快速重传的报文，发送保活报文
+func (a *arq) flush(now time.Time) {
+	// 发送窗口
+	cwnd := a.cfg.sndWnd
+	if a.rmtWnd < cwnd {
+		cwnd = a.rmtWnd
+	}
+	if cwnd < 1 {
+		// 对方窗口为0时每次发送一个报文探测
+		cwnd = 1
+	}
+	for len(a.sndQueue) > 0 && snDiff(a.sndNxt, a.sndUna+uint32(cwnd)) < 0 {
+		s := a.sndQueue[0]
+		a.sndQueue[0] = nil
+		a.sndQueue = a.sndQueue[1:]
+		s.sn = a.sndNxt
+		a.sndNxt++
+		a.sndBuf = append(a.sndBuf, s)
+	}
+
+	for _, s := range a.sndBuf {
+		switch {
+		case s.xmit == 0:
+			s.rto = a.rto
+			s.sentAt = now
+		case !now.Before(s.resendAt):
+			// 超时重传
+			s.rto += s.rto / 2
+		case a.cfg.fastResend > 0 && s.fastack >= a.cfg.fastResend:
+			// 快速重传
+		default:
+			continue
+		}
+		s.xmit++
+		s.fastack = 0
+		s.resendAt = now.Add(s.rto)
+		if a.cfg.deadLink > 0 && s.xmit > a.cfg.deadLink {
+			a.dead = true
+			return
+		}
+		a.emit(s, now)
+	}
+
+	if a.cfg.keepAlive > 0 && now.Sub(a.lastSend) >= a.cfg.keepAlive {
+		a.emit(&segment{cmd: arqCmdPing}, now)
+	}
+}
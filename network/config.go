@@ -2,7 +2,10 @@ package network
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/skeletongo/core/log"
 )
 
 type ServerInfo struct {
@@ -20,7 +23,7 @@ type Service struct {
 
 type SessionConfig struct {
 	Service
-	Protocol          string        // 支持的协议 "tcp" "ws" "wss"
+	Protocol          string        // 支持的协议 "tcp" "ws" "wss" "udp"
 	Ip                string        // ip地址
 	Port              int           // 端口
 	IsClient          bool          // 客户端，链接发起方
@@ -28,16 +31,23 @@ type SessionConfig struct {
 	ReconnectInterval time.Duration // 重连间隔
 	IsInnerLink       bool          // 是否内部链接
 	AuthKey           string        // Authentication Key
-	SupportFragment   bool          // 是否支持分包发送
+	SupportFragment   bool          // tcp 是否支持分包，超过 MaxDataLen 的消息分成多个包发送，接收时合并
+	MaxMsgLen         int           // tcp 分包合并后的最大长度，默认4MB
 	ConnNum           int           // 客户端链接数量
 	AllowMultiConn    bool          // 是否允许多链接
-	Path              string        // ws
-	CertFile          string        // wss Cert
-	KeyFile           string        // wss Key
+	Path              string        // ws 路径，默认为 "/"
+	Origins           []string      // ws 允许的 Origin，为空时只允许和 Host 相同的 Origin，"*" 允许所有
+	TLS               bool          // tcp 是否使用 TLS
+	CertFile          string        // wss/tls 证书文件，修改后自动重新加载；tls 客户端配置后发送客户端证书
+	KeyFile           string        // wss/tls 私钥文件
+	CAFile            string        // tls CA 证书文件，服务端用来验证客户端证书，客户端用来验证服务端证书，客户端为空时使用系统 CA，服务端验证客户端证书时必须配置
+	ClientAuth        string        // tls 服务端验证客户端证书的方式 "none" "request" "require" "verify" "require-verify"，内部链接默认为 "require-verify"
+	TLSMinVersion     string        // tls 最低版本 "1.0" "1.1" "1.2" "1.3"，默认为 "1.2"
+	ServerName        string        // tls 客户端验证的服务端名称，为空时使用 Ip
 	MaxDone           int           // 接收队列缓存大小
 	MaxSend           int           // 发送队列缓存大小
 	MaxConn           int           // 最大链接数量
-	MTU               int
+	MTU               int           // udp 报文最大长度，默认1400
 	Linger            int
 	NoDelay           bool
 	KeepAlive         bool
@@ -47,13 +57,19 @@ type SessionConfig struct {
 	WriteTimeout      time.Duration
 	ReadTimeout       time.Duration
 	IdleTimeout       time.Duration // 多久未收到消息算空闲状态
-	seq               int
+	SndWnd            int           // udp 发送窗口大小，默认32
+	RcvWnd            int           // udp 接收窗口大小，默认128
+	UDPInterval       time.Duration // udp 刷新间隔，单位毫秒，默认10
+	MinRTO            time.Duration // udp 最小重传超时，单位毫秒，默认100
+	FastResend        int           // udp 快速重传，报文被跳过确认的次数达到这个值时立即重传，为0时不开启
+	DeadLink          int           // udp 报文重传次数超过这个值时断开链接，默认20
+	seq               int64
 	pkgDataPool       sync.Pool
 	actionPool        sync.Pool
 
-	//FilterChain []string
-	//sfc         *SessionFilterChain
-	//
+	FilterChain []string // 过滤器名称，按顺序调用，所有会话共用过滤器
+	sfc         *FilterChain
+
 	//HandlerChain []string
 	//shc          *SessionHandlerChain
 	//
@@ -73,18 +89,18 @@ func (sc *SessionConfig) Init() {
 	//	sc.decoder = packet.GetDecoder(sc.DecoderName)
 	//}
 
-	//for i := 0; i < len(sc.FilterChain); i++ {
-	//	creator := GetSessionFilterCreator(sc.FilterChain[i])
-	//	if creator != nil {
-	//		if sc.sfc == nil {
-	//			sc.sfc = NewSessionFilterChain()
-	//		}
-	//		if sc.sfc != nil {
-	//			sc.sfc.AddLast(creator())
-	//		}
-	//	}
-	//}
-	//
+	for i := 0; i < len(sc.FilterChain); i++ {
+		creator := GetFilterCreator(sc.FilterChain[i])
+		if creator == nil {
+			log.Warnf("[%v] Filter not registe", sc.FilterChain[i])
+			continue
+		}
+		if sc.sfc == nil {
+			sc.sfc = NewFilterChain()
+		}
+		sc.sfc.AddLast(creator())
+	}
+
 	//for i := 0; i < len(sc.HandlerChain); i++ {
 	//	creator := GetSessionHandlerCreator(sc.HandlerChain[i])
 	//	if creator != nil {
@@ -127,6 +143,33 @@ func (sc *SessionConfig) Init() {
 		sc.ReconnectInterval *= time.Second
 	}
 	sc.KeepAlivePeriod *= time.Second
+	if sc.SupportFragment && sc.MaxMsgLen <= 0 {
+		sc.MaxMsgLen = 4 << 20
+	}
+	if sc.Protocol == "udp" {
+		if sc.MTU <= arqHeadLen {
+			sc.MTU = 1400
+		}
+		if sc.SndWnd <= 0 {
+			sc.SndWnd = 32
+		}
+		if sc.RcvWnd <= 0 {
+			sc.RcvWnd = 128
+		}
+		if sc.UDPInterval <= 0 {
+			sc.UDPInterval = 10 * time.Millisecond
+		} else {
+			sc.UDPInterval *= time.Millisecond
+		}
+		if sc.MinRTO <= 0 {
+			sc.MinRTO = 100 * time.Millisecond
+		} else {
+			sc.MinRTO *= time.Millisecond
+		}
+		if sc.DeadLink <= 0 {
+			sc.DeadLink = 20
+		}
+	}
 	sc.pkgDataPool.New = func() interface{} {
 		return new(PkgData)
 	}
@@ -135,18 +178,18 @@ func (sc *SessionConfig) Init() {
 	}
 }
 
+// GetSeq 会话序号，goroutine safe
 func (sc *SessionConfig) GetSeq() int {
-	sc.seq++
-	return sc.seq
+	return int(atomic.AddInt64(&sc.seq, 1))
+}
+
+func (sc *SessionConfig) GetFilter(name string) Filter {
+	if sc.sfc != nil {
+		return sc.sfc.GetFilter(name)
+	}
+	return nil
 }
 
-//func (sc *SessionConfig) GetFilter(name string) SessionFilter {
-//	if sc.sfc != nil {
-//		return sc.sfc.GetFilter(name)
-//	}
-//	return nil
-//}
-//
 //func (sc *SessionConfig) GetHandler(name string) SessionHandler {
 //	if sc.shc != nil {
 //		return sc.shc.GetHandler(name)
@@ -5,7 +5,8 @@ import (
 )
 
 type Conn interface {
-	ReadMsg() ([]byte, error)
+	// ReadMsg 读取一个消息和它的逻辑号，只有 tcp 链接的包头携带逻辑号，其它链接的逻辑号为0
+	ReadMsg() ([]byte, uint32, error)
 	WriteMsg([]byte) error
 	LocalAddr() net.Addr
 	RemoteAddr() net.Addr
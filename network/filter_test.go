@@ -0,0 +1,196 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// testFilter 记录事件，拒绝消息6，收到消息8时关闭会话
+type testFilter struct {
+	events chan string
+}
+
+func (f *testFilter) Name() string {
+	return "test"
+}
+
+func (f *testFilter) InterestOps() uint {
+	return 1<<Opened | 1<<Closed | 1<<Idle | 1<<Received | 1<<Send
+}
+
+func (f *testFilter) Opened(s *Session) bool {
+	f.events <- "opened"
+	return true
+}
+
+func (f *testFilter) Closed(s *Session) bool {
+	f.events <- "closed"
+	return true
+}
+
+func (f *testFilter) Idle(s *Session) bool {
+	f.events <- "idle"
+	return true
+}
+
+func (f *testFilter) Received(s *Session, packetid int, logicNo uint32, packet interface{}) bool {
+	f.events <- fmt.Sprint("received ", packetid, " ", logicNo)
+	if packetid == 8 {
+		s.Close()
+	}
+	return packetid != 6
+}
+
+func (f *testFilter) Send(s *Session, packetid int, logicNo uint32, data []byte) bool {
+	f.events <- fmt.Sprint("send ", packetid)
+	return true
+}
+
+func TestFilterChain(t *testing.T) {
+	filter := &testFilter{events: make(chan string, 100)}
+	RegisterFilterCreator("test", func() Filter { return filter })
+	for _, v := range []int{6, 7, 8} {
+		SetHandler(v, new(wsMsg), HandlerWrapper(func(s ISession, msgID int, msg interface{}) error {
+			return s.Send(msgID, msg)
+		}))
+	}
+
+	port := freePort(t)
+	sc := &SessionConfig{Ip: "127.0.0.1", Port: port, MaxConn: 10, MaxDone: 10, MaxSend: 10, FilterChain: []string{"test", "unknown"}}
+	sc.Init()
+	sc.IdleTimeout = 100 * time.Millisecond
+	if sc.GetFilter("test") != filter || sc.GetFilter("unknown") != nil {
+		t.Fatal("1")
+	}
+	srv := NewTCPServer(New(), sc)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+				srv.Update()
+			}
+		}
+	}()
+
+	expect := func(want ...string) {
+		t.Helper()
+		for _, v := range want {
+			select {
+			case e := <-filter.events:
+				if e != v {
+					t.Fatalf("want %v get %v", v, e)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatalf("want %v timeout", v)
+			}
+		}
+	}
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var buf PkgData
+	send := func(msgID int) {
+		data, err := Marshal(msgID, &wsMsg{Text: "hello"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Head.LogicNo = uint32(msgID * 10)
+		ps, err := Encode(&buf, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Write(ps[0])
+	}
+
+	expect("opened")
+	// 拒绝的消息不处理
+	send(6)
+	expect("received 6 60")
+	send(7)
+	expect("received 7 70", "send 7")
+	expect("idle")
+	// 过滤器关闭会话
+	send(8)
+	expect("received 8 80", "closed")
+}
+
+// rejectFilter 拒绝所有会话
+type rejectFilter struct {
+	testFilter
+}
+
+func (f *rejectFilter) Opened(s *Session) bool {
+	f.events <- "opened"
+	return false
+}
+
+func TestFilterReject(t *testing.T) {
+	filter := &rejectFilter{testFilter{events: make(chan string, 100)}}
+	RegisterFilterCreator("reject", func() Filter { return filter })
+	handled := make(chan int, 10)
+	SetHandler(9, new(wsMsg), HandlerWrapper(func(s ISession, msgID int, msg interface{}) error {
+		handled <- msgID
+		return nil
+	}))
+
+	port := freePort(t)
+	sc := &SessionConfig{Ip: "127.0.0.1", Port: port, MaxConn: 10, MaxDone: 10, MaxSend: 10, FilterChain: []string{"reject"}}
+	sc.Init()
+	srv := NewTCPServer(New(), sc)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	data, err := Marshal(9, &wsMsg{Text: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf PkgData
+	ps, err := Encode(&buf, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write(ps[0])
+
+	// 会话事件和消息都在队列中之后才开始处理
+	if e := <-filter.events; e != "received 9 0" {
+		t.Fatal("1", e)
+	}
+	var events []string
+	for len(events) < 2 {
+		srv.Update()
+		select {
+		case e := <-filter.events:
+			events = append(events, e)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if events[0] != "opened" || events[1] != "closed" {
+		t.Error("2", events)
+	}
+	srv.Update()
+	select {
+	case v := <-handled:
+		t.Error("3 rejected session message handled", v)
+	default:
+	}
+}
@@ -69,18 +69,18 @@ func (n *Network) NewService(sc *SessionConfig) IService {
 	if sc.IsClient {
 		switch sc.Protocol {
 		case "ws", "wss":
-
+			s = NewWSClient(n, sc)
 		case "udp":
-
+			s = NewUDPClient(n, sc)
 		default:
 			s = NewTCPClient(n, sc)
 		}
 	} else {
 		switch sc.Protocol {
 		case "ws", "wss":
-
+			s = NewWSServer(n, sc)
 		case "udp":
-
+			s = NewUDPServer(n, sc)
 		default:
 			s = NewTCPServer(n, sc)
 		}
@@ -3,24 +3,37 @@ package network
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 )
 
 // PkgHead 包头
+// 和版本0不兼容：为了支持分包包头从8字节增加到9字节，新增的字节记录包头版本和标志，
+// 旧版本的包头没有这个字节，读到的是应用层协议包头的第一个字节，版本为0，解析时返回版本不匹配的错误
 type PkgHead struct {
 	Len     uint16 // 长度
 	Seq     uint16 // 序号
 	LogicNo uint32 // 逻辑号
+	Flag    uint8  // 高4位是包头版本，低4位是标志
 }
 
+// PkgVersion 包头版本，包头格式修改时增加
+const PkgVersion uint8 = 1
+
+// 包头标志
+const (
+	FlagFragment uint8 = 1 << iota // 分包，后面还有同一个消息的分包
+)
+
 // PkgData 数据包
 type PkgData struct {
 	Head PkgHead
 	//
 	Seq  uint16
 	data []byte // 业务层数据
+	frag []byte // 正在合并的分包数据
 }
 
 var (
@@ -29,43 +42,98 @@ var (
 	MaxDataLen = math.MaxUint16
 )
 
+var (
+	ErrFragmentNotSupported = errors.New("fragment not supported ")
+	ErrEmptyFragment        = errors.New("empty fragment ")
+)
+
+// Encode 消息编码，超过 MaxDataLen 的消息分成多个包，除了最后一个包都设置分包标志
 func Encode(buf *PkgData, data []byte) (packets [][]byte, err error) {
-	dataLen := len(data)
-	if dataLen > MaxDataLen {
-		// 分包
-		for _, v := range PkgCutFunc(data) {
-			ds, err := Encode(buf, v)
-			if err != nil {
-				return nil, err
-			}
-			packets = append(packets, ds...)
+	if len(data) <= MaxDataLen {
+		p, err := encodePkg(buf, data, 0)
+		if err != nil {
+			return nil, err
 		}
-		return
+		return [][]byte{p}, nil
 	}
 
+	// 分包
+	arr := PkgCutFunc(data)
+	for i, v := range arr {
+		var flag uint8
+		if i < len(arr)-1 {
+			flag = FlagFragment
+		}
+		p, err := encodePkg(buf, v, flag)
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, p)
+	}
+	return
+}
+
+func encodePkg(buf *PkgData, data []byte, flag uint8) ([]byte, error) {
 	buf.Seq++
+	buf.Head.Len = uint16(len(data))
+	buf.Head.Seq = buf.Seq
+	buf.Head.Flag = PkgVersion<<4 | flag
 
-	ioBuf := new(bytes.Buffer)
-	if err = binary.Write(ioBuf, defaultEndian, dataLen); err != nil {
-		return nil, err
-	}
-	if err = binary.Write(ioBuf, defaultEndian, buf.Seq); err != nil {
+	ioBuf := bytes.NewBuffer(make([]byte, 0, PkgHeadLen+len(data)))
+	if err := binary.Write(ioBuf, defaultEndian, &buf.Head); err != nil {
 		return nil, err
 	}
-	if err = binary.Write(ioBuf, defaultEndian, buf.Head.LogicNo); err != nil {
+	if _, err := ioBuf.Write(data); err != nil {
 		return nil, err
 	}
-	if _, err = ioBuf.Write(data); err != nil {
-		return nil, err
+	return ioBuf.Bytes(), nil
+}
+
+// Decode 读取一个完整的消息，分包的消息读取所有分包后合并
+// maxLen 分包合并后的最大长度，为0时不支持分包
+// 返回的数据在下次调用前有效
+func Decode(buf *PkgData, r io.Reader, maxLen int) (data []byte, err error) {
+	for {
+		if data, err = decodePkg(buf, r); err != nil {
+			buf.frag = nil
+			return nil, err
+		}
+		fragment := buf.Head.Flag&FlagFragment != 0
+		if !fragment && buf.frag == nil {
+			return data, nil
+		}
+		if maxLen <= 0 {
+			return nil, ErrFragmentNotSupported
+		}
+		if fragment && len(data) == 0 {
+			// 防止用空的分包占用读协程
+			buf.frag = nil
+			return nil, ErrEmptyFragment
+		}
+		if len(buf.frag)+len(data) > maxLen {
+			// 合并前检查长度，不完整的分包最多占用 maxLen 内存
+			buf.frag = nil
+			return nil, fmt.Errorf("fragment len exceed limit %v", maxLen)
+		}
+		buf.frag = append(buf.frag, data...)
+		if !fragment {
+			data = buf.frag
+			buf.frag = nil
+			return data, nil
+		}
 	}
-	return [][]byte{ioBuf.Bytes()}, err
 }
 
-func Decode(buf *PkgData, r io.Reader) (data []byte, err error) {
+func decodePkg(buf *PkgData, r io.Reader) (data []byte, err error) {
 	if err = binary.Read(r, defaultEndian, &buf.Head); err != nil {
 		return
 	}
 
+	if v := buf.Head.Flag >> 4; v != PkgVersion {
+		err = fmt.Errorf("PacketHeader version not matched. get %v want %v", v, PkgVersion)
+		return
+	}
+
 	if int(buf.Head.Len) > MaxDataLen {
 		err = fmt.Errorf("PacketHeader len exceed MaxDataLen. get %v limit %v", buf.Head.Len, MaxDataLen)
 		return
@@ -77,13 +145,19 @@ func Decode(buf *PkgData, r io.Reader) (data []byte, err error) {
 	}
 	buf.Seq++
 
+	if cap(buf.data) < int(buf.Head.Len) {
+		buf.data = make([]byte, MaxDataLen)
+	}
 	data = buf.data[0:buf.Head.Len]
 	_, err = io.ReadFull(r, data)
 	return
 }
 
-// PkgCutFunc 消息分包
+// PkgCutFunc 消息分包，每个包最多 MaxDataLen
 func PkgCutFunc(data []byte) (dataArr [][]byte) {
-
-	return nil
+	for len(data) > MaxDataLen {
+		dataArr = append(dataArr, data[:MaxDataLen])
+		data = data[MaxDataLen:]
+	}
+	return append(dataArr, data)
 }
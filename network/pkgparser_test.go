@@ -0,0 +1,207 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	var wbuf, rbuf PkgData
+	w := new(bytes.Buffer)
+	msgs := [][]byte{
+		[]byte("hello"),
+		bytes.Repeat([]byte{1}, MaxDataLen),
+		bytes.Repeat([]byte{2}, MaxDataLen*2+1),
+		{},
+	}
+	for _, v := range msgs {
+		ps, err := Encode(&wbuf, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := (len(v) + MaxDataLen - 1) / MaxDataLen; len(ps) != n && !(n == 0 && len(ps) == 1) {
+			t.Error("1", len(ps))
+		}
+		for _, p := range ps {
+			w.Write(p)
+		}
+	}
+	for i, v := range msgs {
+		data, err := Decode(&rbuf, w, MaxDataLen*3)
+		if err != nil || !bytes.Equal(data, v) {
+			t.Fatal("2", i, len(data), err)
+		}
+	}
+
+	// 不支持分包
+	wbuf, rbuf = PkgData{}, PkgData{}
+	ps, _ := Encode(&wbuf, msgs[2])
+	w.Reset()
+	for _, p := range ps {
+		w.Write(p)
+	}
+	if _, err := Decode(&rbuf, bytes.NewReader(w.Bytes()), 0); err != ErrFragmentNotSupported {
+		t.Error("3", err)
+	}
+	// 超出合并后的最大长度
+	rbuf = PkgData{}
+	if _, err := Decode(&rbuf, bytes.NewReader(w.Bytes()), MaxDataLen*2); err == nil || rbuf.frag != nil {
+		t.Error("4", err)
+	}
+	// 空的分包
+	wbuf, rbuf = PkgData{}, PkgData{}
+	p, _ := encodePkg(&wbuf, nil, FlagFragment)
+	if _, err := Decode(&rbuf, bytes.NewReader(p), MaxDataLen); err != ErrEmptyFragment {
+		t.Error("5", err)
+	}
+	// 不完整的分包
+	rbuf = PkgData{}
+	if _, err := Decode(&rbuf, bytes.NewReader(ps[0]), MaxDataLen*3); err == nil || rbuf.frag != nil {
+		t.Error("6", err)
+	}
+
+	// 旧版本的8字节包头
+	if PkgHeadLen != 9 {
+		t.Error("7", PkgHeadLen)
+	}
+	data, _ := Marshal(1, &wsMsg{Text: "hello"})
+	old := make([]byte, 8, 8+len(data))
+	defaultEndian.PutUint16(old, uint16(len(data)))
+	defaultEndian.PutUint16(old[2:], 1)
+	rbuf = PkgData{}
+	if _, err := Decode(&rbuf, bytes.NewReader(append(old, data...)), MaxDataLen); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Error("8", err)
+	}
+}
+
+func TestTCPFragment(t *testing.T) {
+	received := make(chan string, 1)
+	SetHandler(5, new(wsMsg), HandlerWrapper(func(s ISession, msgID int, msg interface{}) error {
+		if s.GetSessionConfig().IsClient {
+			received <- msg.(*wsMsg).Text
+			return nil
+		}
+		return s.Send(msgID, &wsMsg{Text: "echo:" + msg.(*wsMsg).Text})
+	}))
+
+	port := freePort(t)
+	sc := &SessionConfig{Ip: "127.0.0.1", Port: port, SupportFragment: true, MaxConn: 10, MaxDone: 10, MaxSend: 10,
+		ReadBuffer: 64 * 1024, WriteBuffer: 64 * 1024}
+	sc.Init()
+	srv := NewTCPServer(New(), sc)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cc := &SessionConfig{Ip: "127.0.0.1", Port: port, IsClient: true, SupportFragment: true, ConnNum: 1, MaxDone: 10, MaxSend: 10}
+	cc.Init()
+	cli := NewTCPClient(New(), cc)
+	if err := cli.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+				srv.Update()
+				cli.Update()
+			}
+		}
+	}()
+
+	var conn net.Conn
+	for i := 0; i < 100 && conn == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		srv.m.Lock()
+		n := len(srv.conns)
+		srv.m.Unlock()
+		if n == 0 {
+			continue
+		}
+		cli.m.Lock()
+		for c := range cli.conns {
+			conn = c
+		}
+		cli.m.Unlock()
+	}
+	if conn == nil {
+		t.Fatal("1 not connected")
+	}
+
+	text := strings.Repeat("a", MaxDataLen*2)
+	data, err := Marshal(5, &wsMsg{Text: text})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 客户端链接的写协程没有发送过消息，从序号1开始发送
+	ps, err := Encode(&PkgData{}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range ps {
+		if _, err = conn.Write(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case v := <-received:
+		if v != "echo:"+text {
+			t.Error("2", len(v))
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("2 timeout")
+	}
+
+	cli.Shutdown()
+	srv.Shutdown()
+	close(done)
+}
+
+func TestTCPFragmentTimeout(t *testing.T) {
+	port := freePort(t)
+	// 内部链接 accept 时不设置读超时，只有合并分包时计时
+	sc := &SessionConfig{Ip: "127.0.0.1", Port: port, IsInnerLink: true, SupportFragment: true, MaxConn: 10, MaxDone: 10, MaxSend: 10}
+	sc.Init()
+	sc.ReadTimeout = 100 * time.Millisecond
+	srv := NewTCPServer(New(), sc)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conns := func() int {
+		srv.m.Lock()
+		defer srv.m.Unlock()
+		return len(srv.conns)
+	}
+
+	// 没有分包时不超时
+	time.Sleep(200 * time.Millisecond)
+	if n := conns(); n != 1 {
+		t.Fatal("1", n)
+	}
+
+	// 只发送第一个分包
+	p, _ := encodePkg(&PkgData{}, bytes.Repeat([]byte{1}, 100), FlagFragment)
+	conn.Write(p)
+	start := time.Now()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("2 not closed")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Error("3", d)
+	}
+}
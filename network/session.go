@@ -1,9 +1,12 @@
 package network
 
 import (
-	"github.com/skeletongo/core/log"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skeletongo/core/log"
 )
 
 type ISession interface {
@@ -19,6 +22,7 @@ type ISession interface {
 }
 
 type pack struct {
+	msgID   int
 	logicNo uint32
 	b       []byte
 }
@@ -46,6 +50,7 @@ func NewSessionState(sc *SessionConfig) *SessionState {
 	}
 	ret.writeBuf.Seq = 0
 	ret.readBuf.Seq = 0
+	ret.readBuf.frag = nil
 	ret.packPool.New = func() interface{} {
 		return new(pack)
 	}
@@ -79,6 +84,7 @@ func (s *SessionState) SendEx(msgID int, logicNo uint32, msg interface{}) error
 	}
 
 	p := s.packPool.Get().(*pack)
+	p.msgID = msgID
 	p.logicNo = logicNo
 	p.b = b
 
@@ -94,6 +100,12 @@ type Session struct {
 	*SessionState
 	conn    Conn
 	actions chan *action
+
+	lastRecv int64 // 最后收到消息的时间 UnixNano
+	idleMu   sync.Mutex
+	idle     *time.Timer
+	isClosed bool
+	rejected bool // 过滤器拒绝了会话，只在主协程中访问
 }
 
 func (a *Session) LocalAddr() net.Addr {
@@ -104,14 +116,106 @@ func (a *Session) RemoteAddr() net.Addr {
 	return a.conn.RemoteAddr()
 }
 
+// unreliableConn 支持不可靠发送的链接
+type unreliableConn interface {
+	WriteUnreliable([]byte) error
+}
+
+// SendUnreliable 不可靠发送，不保证到达和顺序，适合位置同步等可以丢弃的消息
+// 只有 udp 链接支持，消息长度不能超过 MTU，其它链接使用 Send 发送
+func (a *Session) SendUnreliable(msgID int, msg interface{}) error {
+	c, ok := a.conn.(unreliableConn)
+	if !ok {
+		return a.Send(msgID, msg)
+	}
+	b, err := Marshal(msgID, msg)
+	if err != nil {
+		return err
+	}
+	// 不经过写协程，过滤器在调用者的协程中执行
+	if sfc := a.SC.sfc; sfc != nil && !sfc.OnPacketSent(a, msgID, 0, b) {
+		return nil
+	}
+	return c.WriteUnreliable(b)
+}
+
+// post 在主协程中执行
+func (a *Session) post(f func()) {
+	ac := a.SC.actionPool.Get().(*action)
+	ac.s = a
+	ac.f = f
+	a.actions <- ac
+}
+
+// opened 会话开始，在主协程中调用过滤器，需要时开始检查空闲状态
+func (a *Session) opened() {
+	sfc := a.SC.sfc
+	if sfc == nil {
+		return
+	}
+	a.post(func() {
+		if !sfc.OnSessionOpened(a) {
+			a.rejected = true
+			a.Close()
+		}
+	})
+	if a.SC.IdleTimeout > 0 && sfc.filtersInterestOps[Idle].Len() > 0 {
+		atomic.StoreInt64(&a.lastRecv, time.Now().UnixNano())
+		a.idleMu.Lock()
+		a.idle = time.AfterFunc(a.SC.IdleTimeout, a.checkIdle)
+		a.idleMu.Unlock()
+	}
+}
+
+// checkIdle 超过 IdleTimeout 没有收到消息时在主协程中调用过滤器，之后每隔 IdleTimeout 再调用一次
+func (a *Session) checkIdle() {
+	a.idleMu.Lock()
+	defer a.idleMu.Unlock()
+	if a.isClosed {
+		return
+	}
+	d := time.Since(time.Unix(0, atomic.LoadInt64(&a.lastRecv)))
+	if d >= a.SC.IdleTimeout {
+		a.post(func() {
+			if a.rejected {
+				return
+			}
+			if !a.SC.sfc.OnSessionIdle(a) {
+				a.Close()
+			}
+		})
+		d = 0
+	}
+	a.idle.Reset(a.SC.IdleTimeout - d)
+}
+
+// closed 会话结束，在主协程中调用过滤器，这是会话的最后一个事件
+func (a *Session) closed() {
+	sfc := a.SC.sfc
+	if sfc == nil {
+		return
+	}
+	a.idleMu.Lock()
+	a.isClosed = true
+	if a.idle != nil {
+		a.idle.Stop()
+	}
+	a.idleMu.Unlock()
+	a.post(func() {
+		sfc.OnSessionClosed(a)
+	})
+}
+
 // read goroutine
 func (a *Session) ReadMsg() {
+	a.opened()
 	for {
-		data, err := a.conn.ReadMsg()
+		data, logicNo, err := a.conn.ReadMsg()
 		if err != nil {
 			log.Errorf("read message error: %v", err)
 			break
 		}
+		atomic.StoreInt64(&a.lastRecv, time.Now().UnixNano())
 
 		msgID, msg, err := Unmarshal(data)
 		if err != nil {
@@ -119,12 +223,18 @@ func (a *Session) ReadMsg() {
 			break
 		}
 
+		// 过滤器拒绝的消息不处理
+		if sfc := a.SC.sfc; sfc != nil && !sfc.OnPacketReceived(a, msgID, logicNo, msg) {
+			continue
+		}
+
 		ac := a.SC.actionPool.Get().(*action)
 		ac.s = a
 		ac.msgID = msgID
 		ac.msg = msg
 		a.actions <- ac
 	}
+	a.closed()
 
 	a.SC.pkgDataPool.Put(a.readBuf)
 	a.conn.Close()
@@ -136,9 +246,19 @@ func (a *Session) WriteMsg() {
 		if v == nil {
 			break
 		}
+		// 过滤器拒绝的消息不发送
+		if sfc := a.SC.sfc; sfc != nil && !sfc.OnPacketSent(a, v.msgID, v.logicNo, v.b) {
+			a.packPool.Put(v)
+			continue
+		}
 		a.writeBuf.Head.LogicNo = v.logicNo
 		if err := a.conn.WriteMsg(v.b); err != nil {
 			log.Errorf("send message error: %v", err)
+			if err == ErrMsgTooLarge {
+				// 丢弃这个消息，不影响链接
+				a.packPool.Put(v)
+				continue
+			}
 			break
 		}
 
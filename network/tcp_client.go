@@ -1,6 +1,7 @@
 package network
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"sync"
@@ -16,6 +17,8 @@ type TCPClient struct {
 	actions   chan *action // 消息队列，所有链接接收到的消息都进入这个队列
 	closeSign chan struct{}
 	closing   bool
+	tls       *tls.Config
+	certs     *certLoader
 	m         sync.Mutex
 	wg        sync.WaitGroup
 }
@@ -31,6 +34,16 @@ func NewTCPClient(n *Network, sc *SessionConfig) *TCPClient {
 }
 
 func (t *TCPClient) Start() error {
+	if t.SC.TLS {
+		cfg, certs, err := newClientTLSConfig(t.SC)
+		if err != nil {
+			log.Error("TCPClient tls config error:", err)
+			return err
+		}
+		t.tls = cfg
+		t.certs = certs
+	}
+
 	for i := 0; i < t.SC.ConnNum; i++ {
 		t.wg.Add(1)
 		go t.connect(t.SC)
@@ -40,7 +53,7 @@ func (t *TCPClient) Start() error {
 
 func (t *TCPClient) dial(addr string) net.Conn {
 	for {
-		conn, err := net.Dial("tcp", addr)
+		conn, err := t.dialTLS(addr)
 		if err == nil || t.closing {
 			return conn
 		}
@@ -50,6 +63,29 @@ func (t *TCPClient) dial(addr string) net.Conn {
 	}
 }
 
+// dialTLS 建立链接，使用 tls 时完成握手
+func (t *TCPClient) dialTLS(addr string) (net.Conn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil || t.tls == nil {
+		return conn, err
+	}
+	tc := tls.Client(conn, t.tls)
+	if err = tlsHandshake(tc, t.SC); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// ReloadCert 重新加载 tls 客户端证书，已经建立的链接不受影响
+// 证书文件修改后也会自动重新加载
+func (t *TCPClient) ReloadCert() error {
+	if t.certs == nil {
+		return nil
+	}
+	return t.certs.Reload()
+}
+
 func (t *TCPClient) connect(sc *SessionConfig) {
 	defer t.wg.Done()
 
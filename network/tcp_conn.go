@@ -1,12 +1,19 @@
 package network
 
 import (
+	"errors"
 	"net"
+	"sync/atomic"
+	"time"
 )
 
+var ErrFragmentTimeout = errors.New("fragment reassembly timeout ")
+
 type TCPConn struct {
 	*SessionState
-	conn net.Conn
+	conn        net.Conn
+	fragTimer   *time.Timer // 合并分包的计时
+	fragExpired int32       // 合并分包超时，链接已经关闭
 }
 
 func NewTCPConn(conn net.Conn, s *SessionState) *TCPConn {
@@ -25,17 +32,46 @@ func (t *TCPConn) RemoteAddr() net.Addr {
 	return t.conn.RemoteAddr()
 }
 
+// Read 收到第一个分包后开始计时，超过 ReadTimeout 没有收到所有分包时关闭链接，
+// 不完整的分包不能一直占用内存；不修改链接的读超时，不影响 accept 时设置的超时
 func (t *TCPConn) Read(b []byte) (int, error) {
+	if t.readBuf.frag != nil && t.fragTimer == nil && t.SC.ReadTimeout > 0 {
+		t.fragTimer = time.AfterFunc(t.SC.ReadTimeout, func() {
+			atomic.StoreInt32(&t.fragExpired, 1)
+			t.conn.Close()
+		})
+	}
 	return t.conn.Read(b)
 }
 
 // read goroutine
-func (t *TCPConn) ReadMsg() ([]byte, error) {
-	return Decode(t.readBuf, t)
+func (t *TCPConn) ReadMsg() ([]byte, uint32, error) {
+	maxLen := 0
+	if t.SC.SupportFragment {
+		maxLen = t.SC.MaxMsgLen
+	}
+	data, err := Decode(t.readBuf, t, maxLen)
+	if t.fragTimer != nil {
+		if !t.fragTimer.Stop() {
+			// 合并完成时已经超时，链接已经关闭
+			err = ErrFragmentTimeout
+		}
+		t.fragTimer = nil
+	}
+	if err != nil {
+		if atomic.LoadInt32(&t.fragExpired) == 1 {
+			err = ErrFragmentTimeout
+		}
+		return nil, 0, err
+	}
+	return data, t.readBuf.Head.LogicNo, nil
 }
 
 // write goroutine
 func (t *TCPConn) WriteMsg(data []byte) error {
+	if len(data) > MaxDataLen && (!t.SC.SupportFragment || len(data) > t.SC.MaxMsgLen) {
+		return ErrMsgTooLarge
+	}
 	ps, err := Encode(t.writeBuf, data)
 	if err != nil {
 		return err
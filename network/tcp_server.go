@@ -1,6 +1,7 @@
 package network
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"sync"
@@ -17,6 +18,8 @@ type TCPServer struct {
 	closeSign chan struct{}
 	m         sync.Mutex
 	ln        net.Listener
+	tls       *tls.Config
+	certs     *certLoader
 	wgLn      sync.WaitGroup
 	wgConns   sync.WaitGroup
 }
@@ -32,6 +35,16 @@ func NewTCPServer(n *Network, cfg *SessionConfig) *TCPServer {
 }
 
 func (t *TCPServer) Start() error {
+	if t.SC.TLS {
+		cfg, certs, err := newServerTLSConfig(t.SC)
+		if err != nil {
+			log.Error("TCPServer tls config error:", err)
+			return err
+		}
+		t.tls = cfg
+		t.certs = certs
+	}
+
 	ln, err := net.Listen("tcp", fmt.Sprintf("%s:%d", t.SC.Ip, t.SC.Port))
 	if err != nil {
 		log.Error("TCPServer Listen error:", err)
@@ -40,9 +53,9 @@ func (t *TCPServer) Start() error {
 
 	t.ln = ln
 
+	// 监听端口
+	t.wgLn.Add(1)
 	go func() {
-		// 监听端口
-		t.wgLn.Add(1)
 		defer t.wgLn.Done()
 
 		var tempDelay time.Duration
@@ -66,16 +79,6 @@ func (t *TCPServer) Start() error {
 			}
 			tempDelay = 0
 
-			t.m.Lock()
-			if len(t.conns) > t.SC.MaxConn {
-				t.m.Unlock()
-				conn.Close()
-				log.Warn("too many connections")
-				continue
-			}
-			t.conns[conn] = struct{}{}
-			t.m.Unlock()
-
 			c := conn.(*net.TCPConn)
 			if t.SC.IsInnerLink {
 				var timeZero time.Time
@@ -92,38 +95,76 @@ func (t *TCPServer) Start() error {
 			}
 			c.SetLinger(t.SC.Linger)
 			c.SetNoDelay(t.SC.NoDelay)
-			c.SetReadBuffer(t.SC.ReadBuffer)
-			c.SetWriteBuffer(t.SC.WriteBuffer)
+			if t.SC.ReadBuffer > 0 {
+				c.SetReadBuffer(t.SC.ReadBuffer)
+			}
+			if t.SC.WriteBuffer > 0 {
+				c.SetWriteBuffer(t.SC.WriteBuffer)
+			}
 			c.SetKeepAlive(t.SC.KeepAlive)
 			if t.SC.KeepAlive {
 				c.SetKeepAlivePeriod(t.SC.KeepAlivePeriod)
 			}
-
-			t.wgConns.Add(1)
-
-			state := NewSessionState(t.SC)
-			tcpConn := NewTCPConn(conn, state)
-			s := &Session{
-				SessionState: state,
-				conn:         tcpConn,
-				actions:      t.actions,
+			if t.tls != nil {
+				conn = tls.Server(conn, t.tls)
 			}
-			go s.WriteMsg()
-			go func() {
-				s.ReadMsg()
 
-				t.m.Lock()
-				delete(t.conns, conn)
+			t.m.Lock()
+			if len(t.conns) > t.SC.MaxConn {
 				t.m.Unlock()
-				s.Close()
+				conn.Close()
+				log.Warn("too many connections")
+				continue
+			}
+			t.conns[conn] = struct{}{}
+			t.m.Unlock()
 
-				t.wgConns.Done()
-			}()
+			t.wgConns.Add(1)
+			go t.serve(conn)
 		}
 	}()
 	return nil
 }
 
+// serve 链接的读协程
+func (t *TCPServer) serve(conn net.Conn) {
+	defer t.wgConns.Done()
+
+	if tc, ok := conn.(*tls.Conn); ok {
+		if err := tlsHandshake(tc, t.SC); err != nil {
+			log.Debugf("tls handshake with %v error: %v", conn.RemoteAddr(), err)
+			t.m.Lock()
+			delete(t.conns, conn)
+			t.m.Unlock()
+			conn.Close()
+			return
+		}
+	}
+
+	state := NewSessionState(t.SC)
+	s := &Session{
+		SessionState: state,
+		conn:         NewTCPConn(conn, state),
+		actions:      t.actions,
+	}
+	go s.WriteMsg()
+	s.ReadMsg()
+
+	t.m.Lock()
+	delete(t.conns, conn)
+	t.m.Unlock()
+	s.Close()
+}
+
+// ReloadCert 重新加载 tls 证书，已经建立的链接不受影响
+// 证书文件修改后也会自动重新加载
+func (t *TCPServer) ReloadCert() error {
+	if t.certs == nil {
+		return nil
+	}
+	return t.certs.Reload()
+}
+
 func (t *TCPServer) Update() {
 	for {
 		select {
@@ -0,0 +1,217 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/skeletongo/core/log"
+)
+
+var (
+	ErrNoClientCert = errors.New("inner link tls requires client certificate ")
+	ErrNoClientCA   = errors.New("tls client certificate verification requires CAFile ")
+)
+
+// certCheckInterval 检查证书文件是否修改的间隔
+var certCheckInterval = 10 * time.Second
+
+// certLoader 加载证书，证书文件修改后自动重新加载，已经建立的链接不受影响
+type certLoader struct {
+	certFile string
+	keyFile  string
+	m        sync.Mutex
+	cert     *tls.Certificate
+	modTime  time.Time // 证书文件的修改时间
+	checked  time.Time // 上次检查的时间
+}
+
+func newCertLoader(certFile, keyFile string) (*certLoader, error) {
+	l := &certLoader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *certLoader) fileModTime() time.Time {
+	var ret time.Time
+	for _, v := range []string{l.certFile, l.keyFile} {
+		if fi, err := os.Stat(v); err == nil && fi.ModTime().After(ret) {
+			ret = fi.ModTime()
+		}
+	}
+	return ret
+}
+
+// Reload 重新加载证书，失败时继续使用原来的证书
+func (l *certLoader) Reload() error {
+	modTime := l.fileModTime()
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return err
+	}
+	l.m.Lock()
+	l.cert = &cert
+	l.modTime = modTime
+	l.checked = time.Now()
+	l.m.Unlock()
+	return nil
+}
+
+// get 获取证书，距离上次检查超过 certCheckInterval 并且证书文件有修改时重新加载
+func (l *certLoader) get() *tls.Certificate {
+	l.m.Lock()
+	defer l.m.Unlock()
+	now := time.Now()
+	if now.Sub(l.checked) < certCheckInterval {
+		return l.cert
+	}
+	l.checked = now
+	modTime := l.fileModTime()
+	if !modTime.After(l.modTime) {
+		return l.cert
+	}
+	// 证书和私钥可能没有同时更新完成，加载失败时下次检查再试
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		log.Errorf("reload certificate %v error: %v", l.certFile, err)
+		return l.cert
+	}
+	log.Infof("reload certificate %v", l.certFile)
+	l.cert = &cert
+	l.modTime = modTime
+	return l.cert
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificate found in %v", file)
+	}
+	return pool, nil
+}
+
+func tlsVersion(v string) (uint16, error) {
+	switch v {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown tls version %v", v)
+}
+
+func tlsClientAuth(sc *SessionConfig) (tls.ClientAuthType, error) {
+	switch sc.ClientAuth {
+	case "":
+		if sc.IsInnerLink {
+			return tls.RequireAndVerifyClientCert, nil
+		}
+		return tls.NoClientCert, nil
+	case "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	case "verify":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require-verify":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return 0, fmt.Errorf("unknown tls client auth %v", sc.ClientAuth)
+}
+
+// newServerTLSConfig 服务端 TLS 配置
+func newServerTLSConfig(sc *SessionConfig) (*tls.Config, *certLoader, error) {
+	minVersion, err := tlsVersion(sc.TLSMinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	clientAuth, err := tlsClientAuth(sc)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 没有配置 CA 时会使用系统根证书验证客户端证书，内部链接不应该信任外部签发的证书
+	if sc.CAFile == "" && (clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert) {
+		return nil, nil, ErrNoClientCA
+	}
+	certs, err := newCertLoader(sc.CertFile, sc.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion: minVersion,
+		ClientAuth: clientAuth,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return certs.get(), nil
+		},
+	}
+	if sc.CAFile != "" {
+		if cfg.ClientCAs, err = loadCertPool(sc.CAFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	return cfg, certs, nil
+}
+
+// newClientTLSConfig 客户端 TLS 配置，没有配置证书时 certLoader 为 nil
+func newClientTLSConfig(sc *SessionConfig) (*tls.Config, *certLoader, error) {
+	minVersion, err := tlsVersion(sc.TLSMinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion: minVersion,
+		ServerName: sc.ServerName,
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = sc.Ip
+	}
+	if sc.CAFile != "" {
+		if cfg.RootCAs, err = loadCertPool(sc.CAFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	if sc.CertFile == "" {
+		if sc.IsInnerLink {
+			return nil, nil, ErrNoClientCert
+		}
+		return cfg, nil, nil
+	}
+	certs, err := newCertLoader(sc.CertFile, sc.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return certs.get(), nil
+	}
+	return cfg, certs, nil
+}
+
+// tlsHandshake 握手，握手超时使用 ReadTimeout
+// 服务端外部链接在 accept 时已经设置了读写超时，其它链接握手完成后取消超时
+func tlsHandshake(conn *tls.Conn, sc *SessionConfig) error {
+	if (sc.IsClient || sc.IsInnerLink) && sc.ReadTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(sc.ReadTimeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+	return conn.Handshake()
+}
@@ -0,0 +1,155 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// genCert 生成证书，parent 为 nil 时生成 CA 证书
+func genCert(t *testing.T, serial int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: fmt.Sprint("test", serial)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func writeFile(t *testing.T, file string, data []byte, modTime time.Time) {
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func peerSerial(conn net.Conn) int64 {
+	return conn.(*tls.Conn).ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	ca, caKey, caPem, _ := genCert(t, 1, nil, nil)
+	_, _, srvPem, srvKey := genCert(t, 2, ca, caKey)
+	_, _, cliPem, cliKey := genCert(t, 3, ca, caKey)
+	file := func(name string) string { return filepath.Join(dir, name) }
+	writeFile(t, file("ca.pem"), caPem, now)
+	writeFile(t, file("server.pem"), srvPem, now)
+	writeFile(t, file("server.key"), srvKey, now)
+	writeFile(t, file("client.pem"), cliPem, now)
+	writeFile(t, file("client.key"), cliKey, now)
+
+	port := freePort(t)
+	sc := &SessionConfig{Ip: "127.0.0.1", Port: port, IsInnerLink: true, TLS: true, MaxConn: 10, MaxDone: 10, MaxSend: 10,
+		CertFile: file("server.pem"), KeyFile: file("server.key"), TLSMinVersion: "1.3"}
+	sc.Init()
+	// 验证客户端证书必须配置 CA
+	if err := NewTCPServer(New(), sc).Start(); err != ErrNoClientCA {
+		t.Fatal("0", err)
+	}
+	sc.CAFile = file("ca.pem")
+	srv := NewTCPServer(New(), sc)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown()
+
+	// 内部链接必须配置客户端证书
+	cc := &SessionConfig{Ip: "127.0.0.1", Port: port, IsClient: true, IsInnerLink: true, TLS: true, CAFile: file("ca.pem")}
+	cc.Init()
+	if err := NewTCPClient(New(), cc).Start(); err != ErrNoClientCert {
+		t.Error("1", err)
+	}
+
+	// 没有客户端证书
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	pool, _ := loadCertPool(file("ca.pem"))
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool})
+	if err == nil {
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	if err == nil {
+		t.Error("2 client certificate not checked")
+	}
+
+	// 双向认证
+	cc.CertFile, cc.KeyFile = file("client.pem"), file("client.key")
+	cli := NewTCPClient(New(), cc)
+	if err = cli.Start(); err != nil {
+		t.Fatal(err)
+	}
+	conn1, err := cli.dialTLS(addr)
+	if err != nil {
+		t.Fatal("3", err)
+	}
+	defer conn1.Close()
+	if n := peerSerial(conn1); n != 2 {
+		t.Error("4", n)
+	}
+
+	// 证书热更新，已经建立的链接不受影响
+	certCheckInterval = 0
+	defer func() { certCheckInterval = 10 * time.Second }()
+	_, _, srvPem, srvKey = genCert(t, 4, ca, caKey)
+	writeFile(t, file("server.pem"), srvPem, now.Add(time.Second))
+	writeFile(t, file("server.key"), srvKey, now.Add(time.Second))
+	conn2, err := cli.dialTLS(addr)
+	if err != nil {
+		t.Fatal("5", err)
+	}
+	defer conn2.Close()
+	if n := peerSerial(conn2); n != 4 {
+		t.Error("6", n)
+	}
+	srv.m.Lock()
+	n := len(srv.conns)
+	srv.m.Unlock()
+	if n < 2 {
+		t.Error("7", n)
+	}
+}
@@ -0,0 +1,255 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/skeletongo/core/log"
+)
+
+type UDPClient struct {
+	network   *Network
+	SC        *SessionConfig
+	conns     map[*UDPConn]struct{}
+	actions   chan *action // 消息队列，所有链接接收到的消息都进入这个队列
+	closeSign chan struct{}
+	closing   bool
+	m         sync.Mutex
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	wgUpdate  sync.WaitGroup
+}
+
+func NewUDPClient(n *Network, sc *SessionConfig) *UDPClient {
+	return &UDPClient{
+		network:   n,
+		SC:        sc,
+		conns:     make(map[*UDPConn]struct{}),
+		actions:   make(chan *action, sc.MaxDone),
+		closeSign: make(chan struct{}),
+		stop:      make(chan struct{}),
+	}
+}
+
+func (u *UDPClient) Start() error {
+	u.wgUpdate.Add(1)
+	go u.update()
+	for i := 0; i < u.SC.ConnNum; i++ {
+		u.wg.Add(1)
+		go u.connect(u.SC)
+	}
+	return nil
+}
+
+func (u *UDPClient) isClosing() bool {
+	u.m.Lock()
+	defer u.m.Unlock()
+	return u.closing
+}
+
+func (u *UDPClient) dial(addr string) *net.UDPConn {
+	for {
+		conn, err := u.dialUDP(addr)
+		if err == nil || u.isClosing() {
+			return conn
+		}
+
+		log.Infof("connect to %v error: %v", addr, err)
+		time.Sleep(u.SC.ReconnectInterval)
+	}
+}
+
+func (u *UDPClient) dialUDP(addr string) (*net.UDPConn, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil, err
+	}
+	if u.SC.ReadBuffer > 0 {
+		conn.SetReadBuffer(u.SC.ReadBuffer)
+	}
+	if u.SC.WriteBuffer > 0 {
+		conn.SetWriteBuffer(u.SC.WriteBuffer)
+	}
+	return conn, nil
+}
+
+func (u *UDPClient) connect(sc *SessionConfig) {
+	defer u.wg.Done()
+
+reconnect:
+	addr := fmt.Sprintf("%v:%v", sc.Ip, sc.Port)
+	conn := u.dial(addr)
+	if conn == nil {
+		return
+	}
+
+	conv := rand.Uint32() ^ uint32(time.Now().UnixNano())
+	if err := u.handshake(conn, conv); err != nil {
+		conn.Close()
+		log.Infof("handshake with %v error: %v", addr, err)
+		if u.SC.AutoReconnect && !u.isClosing() {
+			time.Sleep(u.SC.ReconnectInterval)
+			goto reconnect
+		}
+		return
+	}
+
+	state := NewSessionState(sc)
+	c := newUDPConn(conv, conn, nil, state)
+	c.onClose = func() {
+		conn.Close()
+	}
+
+	u.m.Lock()
+	if u.closing {
+		u.m.Unlock()
+		conn.Close()
+		return
+	}
+	u.conns[c] = struct{}{}
+	u.m.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				c.m.Lock()
+				closed := c.err != nil
+				c.m.Unlock()
+				if closed {
+					return
+				}
+				// 对方端口不可达等错误，等待重传或者超时
+				continue
+			}
+			c.input(buf[:n])
+		}
+	}()
+
+	s := &Session{
+		SessionState: state,
+		conn:         c,
+		actions:      u.actions,
+	}
+	go s.WriteMsg()
+	s.ReadMsg()
+
+	u.m.Lock()
+	delete(u.conns, c)
+	u.m.Unlock()
+	s.Close()
+	<-done
+
+	if u.SC.AutoReconnect && !u.isClosing() {
+		time.Sleep(u.SC.ReconnectInterval)
+		goto reconnect
+	}
+}
+
+// handshake 握手，先发送空 cookie 获取服务端的 cookie，再带上 cookie 请求建立链接，
+// 服务端回复相同的 cookie 表示链接已经建立
+func (u *UDPClient) handshake(conn *net.UDPConn, conv uint32) error {
+	defer conn.SetReadDeadline(time.Time{})
+
+	cookie := make([]byte, udpCookieLen)
+	buf := make([]byte, 64*1024)
+	rto := u.SC.MinRTO
+	deadline := time.Now().Add(u.SC.ReadTimeout)
+	for !u.isClosing() {
+		if time.Now().After(deadline) {
+			return ErrUDPTimeout
+		}
+		conn.Write((&segment{cmd: arqCmdSyn, data: cookie}).encode(conv))
+		conn.SetReadDeadline(time.Now().Add(rto))
+		n, err := conn.Read(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+				// 对方端口不可达等错误，等待后重试
+				time.Sleep(rto)
+			}
+			if rto *= 2; rto > udpMaxSynRTO {
+				rto = udpMaxSynRTO
+			}
+			continue
+		}
+		c, s, ok := decodeSegment(buf[:n])
+		if !ok || c != conv || s.cmd != arqCmdSynAck || len(s.data) != udpCookieLen {
+			continue
+		}
+		if bytes.Equal(s.data, cookie) {
+			return nil
+		}
+		copy(cookie, s.data)
+	}
+	return ErrUDPClosed
+}
+
+// update 定时刷新所有链接
+func (u *UDPClient) update() {
+	defer u.wgUpdate.Done()
+
+	ticker := time.NewTicker(u.SC.UDPInterval)
+	defer ticker.Stop()
+	var conns []*UDPConn
+	for {
+		select {
+		case <-u.stop:
+			return
+		case now := <-ticker.C:
+			u.m.Lock()
+			for c := range u.conns {
+				conns = append(conns, c)
+			}
+			u.m.Unlock()
+			for i, c := range conns {
+				c.update(now)
+				conns[i] = nil
+			}
+			conns = conns[:0]
+		}
+	}
+}
+
+func (u *UDPClient) Update() {
+	for {
+		select {
+		case <-u.closeSign:
+			u.network.ServiceClosed(u.SC)
+		case v := <-u.actions:
+			v.do()
+			u.SC.actionPool.Put(v)
+		default:
+			return
+		}
+	}
+}
+
+func (u *UDPClient) Shutdown() {
+	u.m.Lock()
+	u.closing = true
+	conns := make([]*UDPConn, 0, len(u.conns))
+	for c := range u.conns {
+		conns = append(conns, c)
+	}
+	u.conns = nil
+	u.m.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+	u.wg.Wait()
+
+	close(u.stop)
+	u.wgUpdate.Wait()
+	close(u.closeSign)
+}
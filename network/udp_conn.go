@@ -0,0 +1,182 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUDPTimeout      = errors.New("udp read timeout ")
+	ErrUDPWriteTimeout = errors.New("udp write timeout ")
+	ErrUDPClosed       = errors.New("udp link closed ")
+)
+
+// UDPConn udp 链接，在 arq 上实现可靠有序的消息传输，每个消息是一个完整的应用层数据，不需要包头
+// 读超时对内部链接同样有效，双方会定时发送保活报文
+type UDPConn struct {
+	*SessionState
+	m         sync.Mutex
+	arq       *arq
+	conn      *net.UDPConn
+	remote    *net.UDPAddr // 服务端链接的对方地址，客户端链接为 nil
+	readable  chan struct{}
+	writable  chan struct{}
+	err       error // 链接关闭的原因
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func newUDPConn(conv uint32, conn *net.UDPConn, remote *net.UDPAddr, s *SessionState) *UDPConn {
+	c := &UDPConn{
+		SessionState: s,
+		conn:         conn,
+		remote:       remote,
+		readable:     make(chan struct{}, 1),
+		writable:     make(chan struct{}, 1),
+	}
+	c.arq = newArq(conv, newArqConfig(s.SC), c.output)
+	return c
+}
+
+func (c *UDPConn) output(b []byte) {
+	if c.remote != nil {
+		c.conn.WriteToUDP(b, c.remote)
+	} else {
+		c.conn.Write(b)
+	}
+}
+
+func (c *UDPConn) notify() {
+	select {
+	case c.readable <- struct{}{}:
+	default:
+	}
+	select {
+	case c.writable <- struct{}{}:
+	default:
+	}
+}
+
+func (c *UDPConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *UDPConn) RemoteAddr() net.Addr {
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.conn.RemoteAddr()
+}
+
+// read goroutine
+func (c *UDPConn) ReadMsg() ([]byte, uint32, error) {
+	for {
+		c.m.Lock()
+		data, ok := c.arq.recv()
+		err := c.err
+		c.m.Unlock()
+		if ok {
+			return data, 0, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		<-c.readable
+	}
+}
+
+// write goroutine
+// 等待发送的报文太多时阻塞，直到对方确认或者写超时
+func (c *UDPConn) WriteMsg(data []byte) error {
+	var timeout <-chan time.Time
+	if c.SC.WriteTimeout > 0 {
+		t := time.NewTimer(c.SC.WriteTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	for {
+		c.m.Lock()
+		if c.err != nil {
+			c.m.Unlock()
+			return c.err
+		}
+		if len(c.arq.sndQueue) < c.arq.cfg.sndWnd*2 {
+			err := c.arq.send(data)
+			if err == nil {
+				c.arq.flush(time.Now())
+			}
+			c.m.Unlock()
+			return err
+		}
+		c.m.Unlock()
+
+		select {
+		case <-c.writable:
+		case <-timeout:
+			return ErrUDPWriteTimeout
+		}
+	}
+}
+
+// WriteUnreliable 不可靠发送，立即发送一次，不重传，消息长度不能超过 MTU
+// goroutine safe
+func (c *UDPConn) WriteUnreliable(data []byte) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return c.arq.sendUnreliable(data, time.Now())
+}
+
+// input 收到报文
+func (c *UDPConn) input(b []byte) {
+	c.m.Lock()
+	if c.err == nil {
+		c.arq.input(b, time.Now())
+		switch {
+		case c.arq.fin:
+			c.err = io.EOF
+		case c.arq.dead:
+			c.err = ErrDeadLink
+		}
+	}
+	c.m.Unlock()
+	c.notify()
+}
+
+// update 定时刷新，发送和重传报文，检查链接状态
+func (c *UDPConn) update(now time.Time) {
+	c.m.Lock()
+	if c.err != nil {
+		c.m.Unlock()
+		return
+	}
+	c.arq.flush(now)
+	switch {
+	case c.arq.dead:
+		c.err = ErrDeadLink
+	case c.SC.ReadTimeout > 0 && now.Sub(c.arq.lastRecv) > c.SC.ReadTimeout:
+		c.err = ErrUDPTimeout
+	}
+	c.m.Unlock()
+	c.notify()
+}
+
+func (c *UDPConn) Close() {
+	c.closeOnce.Do(func() {
+		c.m.Lock()
+		if c.err == nil {
+			c.arq.sendFin(time.Now())
+			c.err = ErrUDPClosed
+		}
+		c.m.Unlock()
+		c.notify()
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+}
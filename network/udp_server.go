@@ -0,0 +1,274 @@
+package network
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/skeletongo/core/log"
+)
+
+const (
+	udpCookieLen  = 8                 // 握手 cookie 长度
+	udpCookieSlot = 10 * time.Second  // cookie 有效时段，当前和上一个时段的 cookie 都有效
+	udpClosedKeep = 2 * udpCookieSlot // 关闭的链接记录保留时长，覆盖 cookie 的有效期
+	udpMaxSynRTO  = time.Second       // 客户端握手的最大重传间隔
+)
+
+// closedConv 最近关闭的链接
+type closedConv struct {
+	conv uint32
+	at   time.Time
+}
+
+// UDPServer udp 服务端，所有链接共用一个 socket，按对方地址区分链接
+// 建立链接需要握手：客户端先发送空 cookie 的握手请求，服务端回复根据对方地址和会话号计算的 cookie，
+// 客户端带上 cookie 再次请求后才创建链接，伪造源地址的报文收不到 cookie，无法占用链接
+type UDPServer struct {
+	network   *Network
+	SC        *SessionConfig
+	conns     map[string]*UDPConn
+	closed    map[string]closedConv // 每个地址最近关闭的链接，迟到的握手请求不会重新创建链接
+	secret    []byte                // 计算 cookie 的密钥
+	actions   chan *action          // 消息队列，所有链接接收到的消息都进入这个队列
+	closeSign chan struct{}
+	closing   bool
+	m         sync.Mutex
+	conn      *net.UDPConn
+	stop      chan struct{}
+	wgLn      sync.WaitGroup
+	wgConns   sync.WaitGroup
+}
+
+func NewUDPServer(n *Network, cfg *SessionConfig) *UDPServer {
+	return &UDPServer{
+		network:   n,
+		SC:        cfg,
+		conns:     make(map[string]*UDPConn),
+		closed:    make(map[string]closedConv),
+		actions:   make(chan *action, cfg.MaxDone),
+		closeSign: make(chan struct{}),
+		stop:      make(chan struct{}),
+	}
+}
+
+func (u *UDPServer) Start() error {
+	u.secret = make([]byte, 32)
+	if _, err := rand.Read(u.secret); err != nil {
+		log.Error("UDPServer generate secret error:", err)
+		return err
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", u.SC.Ip, u.SC.Port))
+	if err != nil {
+		log.Error("UDPServer ResolveUDPAddr error:", err)
+		return err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		log.Error("UDPServer Listen error:", err)
+		return err
+	}
+	if u.SC.ReadBuffer > 0 {
+		conn.SetReadBuffer(u.SC.ReadBuffer)
+	}
+	if u.SC.WriteBuffer > 0 {
+		conn.SetWriteBuffer(u.SC.WriteBuffer)
+	}
+	u.conn = conn
+
+	u.wgLn.Add(2)
+	go u.read()
+	go u.update()
+	return nil
+}
+
+func (u *UDPServer) read() {
+	defer u.wgLn.Done()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := u.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		conv, seg, ok := decodeSegment(buf[:n])
+		if !ok {
+			continue
+		}
+
+		key := addr.String()
+		if seg.cmd == arqCmdSyn {
+			u.syn(conv, seg.data, addr, key)
+			continue
+		}
+		u.m.Lock()
+		c := u.conns[key]
+		u.m.Unlock()
+
+		// 没有握手的报文，以及同一地址还没有超时的旧链接的报文都丢弃
+		if c == nil || c.arq.conv != conv {
+			continue
+		}
+		c.input(buf[:n])
+	}
+}
+
+// syn 处理握手请求，cookie 有效才创建链接
+func (u *UDPServer) syn(conv uint32, cookie []byte, addr *net.UDPAddr, key string) {
+	if len(cookie) != udpCookieLen {
+		// 回复不比请求大，不会被用来放大流量
+		return
+	}
+	now := time.Now()
+	slot := now.Unix() / int64(udpCookieSlot/time.Second)
+	u.m.Lock()
+	c := u.conns[key]
+	switch {
+	case c != nil:
+		u.m.Unlock()
+		if c.arq.conv == conv {
+			// 重传的握手请求，对方没有收到回复
+			u.synAck(conv, cookie, addr)
+		}
+		return
+	case !hmac.Equal(cookie, u.cookie(key, conv, slot)) && !hmac.Equal(cookie, u.cookie(key, conv, slot-1)):
+		u.m.Unlock()
+		u.synAck(conv, u.cookie(key, conv, slot), addr)
+		return
+	case u.closing:
+		u.m.Unlock()
+		return
+	}
+	if v, ok := u.closed[key]; ok && v.conv == conv && now.Sub(v.at) < udpClosedKeep {
+		// 已经关闭的链接迟到的握手请求
+		u.m.Unlock()
+		return
+	}
+	if len(u.conns) >= u.SC.MaxConn {
+		u.m.Unlock()
+		log.Warn("too many connections")
+		return
+	}
+	u.newConn(conv, addr, key)
+	u.m.Unlock()
+	u.synAck(conv, cookie, addr)
+}
+
+func (u *UDPServer) synAck(conv uint32, cookie []byte, addr *net.UDPAddr) {
+	u.conn.WriteToUDP((&segment{cmd: arqCmdSynAck, data: cookie}).encode(conv), addr)
+}
+
+// cookie 根据对方地址、会话号和时段计算 cookie
+func (u *UDPServer) cookie(key string, conv uint32, slot int64) []byte {
+	b := make([]byte, 12, 12+len(key))
+	defaultEndian.PutUint32(b, conv)
+	defaultEndian.PutUint64(b[4:], uint64(slot))
+	mac := hmac.New(sha256.New, u.secret)
+	mac.Write(append(b, key...))
+	return mac.Sum(nil)[:udpCookieLen]
+}
+
+// newConn 创建链接，调用前加锁
+func (u *UDPServer) newConn(conv uint32, addr *net.UDPAddr, key string) *UDPConn {
+	state := NewSessionState(u.SC)
+	c := newUDPConn(conv, u.conn, addr, state)
+	c.onClose = func() {
+		u.m.Lock()
+		if u.conns[key] == c {
+			delete(u.conns, key)
+			u.closed[key] = closedConv{conv: conv, at: time.Now()}
+		}
+		u.m.Unlock()
+	}
+	u.conns[key] = c
+
+	u.wgConns.Add(1)
+	s := &Session{
+		SessionState: state,
+		conn:         c,
+		actions:      u.actions,
+	}
+	go s.WriteMsg()
+	go func() {
+		s.ReadMsg()
+		s.Close()
+		u.wgConns.Done()
+	}()
+	return c
+}
+
+// update 定时刷新所有链接
+func (u *UDPServer) update() {
+	defer u.wgLn.Done()
+
+	ticker := time.NewTicker(u.SC.UDPInterval)
+	defer ticker.Stop()
+	var conns []*UDPConn
+	var sweep time.Time
+	for {
+		select {
+		case <-u.stop:
+			return
+		case now := <-ticker.C:
+			u.m.Lock()
+			for _, c := range u.conns {
+				conns = append(conns, c)
+			}
+			if now.Sub(sweep) >= udpCookieSlot {
+				sweep = now
+				for k, v := range u.closed {
+					if now.Sub(v.at) >= udpClosedKeep {
+						delete(u.closed, k)
+					}
+				}
+			}
+			u.m.Unlock()
+			for i, c := range conns {
+				c.update(now)
+				conns[i] = nil
+			}
+			conns = conns[:0]
+		}
+	}
+}
+
+func (u *UDPServer) Update() {
+	for {
+		select {
+		case <-u.closeSign:
+			u.network.ServiceClosed(u.SC)
+		case v := <-u.actions:
+			v.do()
+			u.SC.actionPool.Put(v)
+		default:
+			return
+		}
+	}
+}
+
+func (u *UDPServer) Shutdown() {
+	u.m.Lock()
+	u.closing = true
+	conns := make([]*UDPConn, 0, len(u.conns))
+	for _, c := range u.conns {
+		conns = append(conns, c)
+	}
+	u.m.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+	u.wgConns.Wait()
+
+	close(u.stop)
+	u.conn.Close()
+	u.wgLn.Wait()
+	close(u.closeSign)
+}
@@ -0,0 +1,348 @@
+package network
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArq(t *testing.T) {
+	cfg := &arqConfig{
+		mtu:        64,
+		sndWnd:     8,
+		rcvWnd:     16,
+		interval:   10 * time.Millisecond,
+		minRTO:     30 * time.Millisecond,
+		fastResend: 2,
+		deadLink:   100,
+	}
+	r := rand.New(rand.NewSource(1))
+	// 丢包率30%，乱序
+	var ab, ba [][]byte
+	lossy := func(q *[][]byte) func(b []byte) {
+		return func(b []byte) {
+			if r.Intn(10) < 3 {
+				return
+			}
+			*q = append(*q, b)
+			if n := len(*q); n > 1 && r.Intn(2) == 0 {
+				(*q)[n-1], (*q)[n-2] = (*q)[n-2], (*q)[n-1]
+			}
+		}
+	}
+	a := newArq(1, cfg, lossy(&ab))
+	b := newArq(1, cfg, lossy(&ba))
+
+	var msgs [][]byte
+	for i := 0; i < 50; i++ {
+		msg := bytes.Repeat([]byte{byte(i)}, r.Intn(200)+1)
+		msgs = append(msgs, msg)
+		if err := a.send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.send(make([]byte, (cfg.mtu-arqHeadLen)*arqMaxFrg+1)); err != ErrMsgTooLarge {
+		t.Error("1", err)
+	}
+
+	now := time.Now()
+	var got [][]byte
+	for i := 0; i < 10000 && len(got) < len(msgs); i++ {
+		now = now.Add(cfg.interval)
+		a.flush(now)
+		b.flush(now)
+		for _, v := range ab {
+			b.input(v, now)
+		}
+		ab = ab[:0]
+		for _, v := range ba {
+			a.input(v, now)
+		}
+		ba = ba[:0]
+		for {
+			msg, ok := b.recv()
+			if !ok {
+				break
+			}
+			got = append(got, msg)
+		}
+	}
+	if len(got) != len(msgs) {
+		t.Fatal("2", len(got))
+	}
+	for i := range msgs {
+		if !bytes.Equal(got[i], msgs[i]) {
+			t.Fatal("3", i)
+		}
+	}
+	if a.dead || b.dead {
+		t.Error("4 dead")
+	}
+}
+
+func TestArqDeadLink(t *testing.T) {
+	cfg := &arqConfig{mtu: 64, sndWnd: 8, rcvWnd: 16, interval: 10 * time.Millisecond, minRTO: 30 * time.Millisecond, deadLink: 3}
+	a := newArq(1, cfg, func(b []byte) {})
+	a.send([]byte("hello"))
+	now := time.Now()
+	for i := 0; i < 100 && !a.dead; i++ {
+		now = now.Add(time.Second)
+		a.flush(now)
+	}
+	if !a.dead {
+		t.Error("not dead")
+	}
+}
+
+func TestArqRcvWnd(t *testing.T) {
+	cfg := &arqConfig{mtu: 64, sndWnd: 8, rcvWnd: 16, interval: 10 * time.Millisecond, minRTO: 30 * time.Millisecond}
+	b := newArq(1, cfg, func([]byte) {})
+	push := func(sn uint32, frg uint8) {
+		b.input((&segment{cmd: arqCmdPush, sn: sn, frg: frg, wnd: 16, data: []byte{byte(sn)}}).encode(1), time.Now())
+	}
+
+	// 发送方不理会接收窗口，接收方不读取
+	for sn := uint32(0); sn < 100000; sn++ {
+		push(sn, 0)
+	}
+	if len(b.rcvQueue) != cfg.rcvWnd || len(b.rcvBuf) != cfg.rcvWnd || b.wnd() != 0 {
+		t.Fatal("1", len(b.rcvQueue), len(b.rcvBuf), b.wnd())
+	}
+	// 读取后等待的报文进入接收队列
+	for i := 0; i < 2*cfg.rcvWnd; i++ {
+		msg, ok := b.recv()
+		if !ok || msg[0] != byte(i) {
+			t.Fatal("2", i, ok, msg)
+		}
+	}
+	if _, ok := b.recv(); ok || b.wnd() != uint16(cfg.rcvWnd) || b.dead {
+		t.Fatal("3", b.wnd())
+	}
+
+	// 片段数量超出接收窗口
+	b = newArq(1, cfg, func([]byte) {})
+	push(0, uint8(cfg.rcvWnd))
+	if !b.dead {
+		t.Error("4")
+	}
+	// 片段数量和正在组成的消息不一致
+	b = newArq(1, cfg, func([]byte) {})
+	push(0, 2)
+	push(1, 1)
+	push(2, 1)
+	if !b.dead {
+		t.Error("5")
+	}
+	// 超出接收窗口的消息不能发送
+	if err := b.send(make([]byte, (cfg.mtu-arqHeadLen)*cfg.rcvWnd+1)); err != ErrMsgTooLarge {
+		t.Error("6", err)
+	}
+}
+
+func freeUDPPort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestUDP(t *testing.T) {
+	received := make(chan string, 10)
+	// 3 不可靠消息，4 可靠消息
+	echo := HandlerWrapper(func(s ISession, msgID int, msg interface{}) error {
+		if s.GetSessionConfig().IsClient {
+			received <- msg.(*wsMsg).Text
+			return nil
+		}
+		if msgID == 3 {
+			return s.(*Session).SendUnreliable(msgID, &wsMsg{Text: "echo:" + msg.(*wsMsg).Text})
+		}
+		return s.Send(msgID, &wsMsg{Text: "echo:" + msg.(*wsMsg).Text})
+	})
+	SetHandler(3, new(wsMsg), echo)
+	SetHandler(4, new(wsMsg), echo)
+
+	port := freeUDPPort(t)
+	sc := &SessionConfig{Protocol: "udp", Ip: "127.0.0.1", Port: port, MaxConn: 1, MaxDone: 10, MaxSend: 10}
+	sc.Init()
+	srv := NewUDPServer(New(), sc)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cc := &SessionConfig{Protocol: "udp", Ip: "127.0.0.1", Port: port, IsClient: true, ConnNum: 1, MaxDone: 10, MaxSend: 10}
+	cc.Init()
+	cli := NewUDPClient(New(), cc)
+	if err := cli.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+				srv.Update()
+				cli.Update()
+			}
+		}
+	}()
+
+	var s *Session
+	for i := 0; i < 100 && s == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		cli.m.Lock()
+		for c := range cli.conns {
+			s = &Session{SessionState: c.SessionState, conn: c}
+		}
+		cli.m.Unlock()
+	}
+	if s == nil {
+		t.Fatal("1 not connected")
+	}
+
+	// 可靠发送，大消息分片
+	text := strings.Repeat("a", 5000)
+	if err := s.Send(4, &wsMsg{Text: text}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-received:
+		if v != "echo:"+text {
+			t.Error("2", len(v))
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("2 timeout")
+	}
+
+	// 不可靠发送
+	if err := s.SendUnreliable(3, &wsMsg{Text: "pos"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-received:
+		if v != "echo:pos" {
+			t.Error("3", v)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("3 timeout")
+	}
+	if err := s.SendUnreliable(3, &wsMsg{Text: text}); err != ErrMsgTooLarge {
+		t.Error("4", err)
+	}
+
+	// 超出最大链接数量
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	cookie := rawSyn(t, conn, 2, make([]byte, udpCookieLen))
+	conn.Write((&segment{cmd: arqCmdSyn, data: cookie}).encode(2))
+	time.Sleep(50 * time.Millisecond)
+	srv.m.Lock()
+	n := len(srv.conns)
+	srv.m.Unlock()
+	if n != 1 {
+		t.Error("5 MaxConn not checked", n)
+	}
+
+	cli.Shutdown()
+	srv.Shutdown()
+	close(done)
+}
+
+// rawSyn 发送握手请求，返回服务端回复的 cookie
+func rawSyn(t *testing.T, conn *net.UDPConn, conv uint32, cookie []byte) []byte {
+	t.Helper()
+	conn.Write((&segment{cmd: arqCmdSyn, data: cookie}).encode(conv))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal("syn ack", err)
+	}
+	c, s, ok := decodeSegment(buf[:n])
+	if !ok || c != conv || s.cmd != arqCmdSynAck || len(s.data) != udpCookieLen {
+		t.Fatal("syn ack", c, s)
+	}
+	return s.data
+}
+
+func TestUDPHandshake(t *testing.T) {
+	port := freeUDPPort(t)
+	sc := &SessionConfig{Protocol: "udp", Ip: "127.0.0.1", Port: port, MaxConn: 10, MaxDone: 10, MaxSend: 10}
+	sc.Init()
+	srv := NewUDPServer(New(), sc)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown()
+	conns := func() int {
+		srv.m.Lock()
+		defer srv.m.Unlock()
+		return len(srv.conns)
+	}
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 没有握手的报文不创建链接
+	conn.Write((&segment{cmd: arqCmdPush, data: []byte("hello")}).encode(1))
+	conn.Write((&segment{cmd: arqCmdPing}).encode(1))
+	time.Sleep(50 * time.Millisecond)
+	if n := conns(); n != 0 {
+		t.Fatal("1", n)
+	}
+
+	// 空 cookie 和错误的 cookie 只回复 cookie，不创建链接
+	zero := make([]byte, udpCookieLen)
+	cookie := rawSyn(t, conn, 1, zero)
+	if bytes.Equal(cookie, zero) || !bytes.Equal(rawSyn(t, conn, 1, []byte("12345678")), cookie) {
+		t.Fatal("2", cookie)
+	}
+	if n := conns(); n != 0 {
+		t.Fatal("3", n)
+	}
+	// cookie 和会话号绑定
+	if bytes.Equal(rawSyn(t, conn, 2, zero), cookie) {
+		t.Fatal("4")
+	}
+
+	// 带上 cookie 建立链接，重传的握手请求不会重复创建
+	if !bytes.Equal(rawSyn(t, conn, 1, cookie), cookie) || !bytes.Equal(rawSyn(t, conn, 1, cookie), cookie) {
+		t.Fatal("5")
+	}
+	if n := conns(); n != 1 {
+		t.Fatal("6", n)
+	}
+
+	// 关闭后迟到的握手请求和数据报文不会重新创建链接
+	conn.Write((&segment{cmd: arqCmdFin}).encode(1))
+	time.Sleep(50 * time.Millisecond)
+	if n := conns(); n != 0 {
+		t.Fatal("7", n)
+	}
+	conn.Write((&segment{cmd: arqCmdSyn, data: cookie}).encode(1))
+	conn.Write((&segment{cmd: arqCmdPush, data: []byte("hello")}).encode(1))
+	time.Sleep(50 * time.Millisecond)
+	if n := conns(); n != 0 {
+		t.Fatal("8", n)
+	}
+
+	// 新的会话号可以重新建立链接
+	cookie = rawSyn(t, conn, 3, zero)
+	rawSyn(t, conn, 3, cookie)
+	if n := conns(); n != 1 {
+		t.Fatal("9", n)
+	}
+}
@@ -0,0 +1,150 @@
+package network
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/skeletongo/core/log"
+)
+
+type WSClient struct {
+	network   *Network
+	SC        *SessionConfig
+	conns     map[*websocket.Conn]struct{}
+	actions   chan *action // 消息队列，所有链接接收到的消息都进入这个队列
+	closeSign chan struct{}
+	closing   bool
+	dialer    websocket.Dialer
+	certs     *certLoader
+	m         sync.Mutex
+	wg        sync.WaitGroup
+}
+
+func NewWSClient(n *Network, sc *SessionConfig) *WSClient {
+	return &WSClient{
+		network:   n,
+		SC:        sc,
+		conns:     make(map[*websocket.Conn]struct{}),
+		actions:   make(chan *action, sc.MaxDone),
+		closeSign: make(chan struct{}),
+		dialer: websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: sc.ReadTimeout,
+			ReadBufferSize:   sc.ReadBuffer,
+			WriteBufferSize:  sc.WriteBuffer,
+		},
+	}
+}
+
+func (w *WSClient) Start() error {
+	if w.SC.Protocol == "wss" {
+		cfg, certs, err := newClientTLSConfig(w.SC)
+		if err != nil {
+			log.Error("WSClient tls config error:", err)
+			return err
+		}
+		w.dialer.TLSClientConfig = cfg
+		w.certs = certs
+	}
+	for i := 0; i < w.SC.ConnNum; i++ {
+		w.wg.Add(1)
+		go w.connect(w.SC)
+	}
+	return nil
+}
+
+// ReloadCert 重新加载 wss 客户端证书，已经建立的链接不受影响
+// 证书文件修改后也会自动重新加载
+func (w *WSClient) ReloadCert() error {
+	if w.certs == nil {
+		return nil
+	}
+	return w.certs.Reload()
+}
+
+func (w *WSClient) isClosing() bool {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.closing
+}
+
+func (w *WSClient) dial(addr string) *websocket.Conn {
+	for {
+		conn, _, err := w.dialer.Dial(addr, nil)
+		if err == nil || w.isClosing() {
+			return conn
+		}
+
+		log.Infof("connect to %v error: %v", addr, err)
+		time.Sleep(w.SC.ReconnectInterval)
+	}
+}
+
+func (w *WSClient) connect(sc *SessionConfig) {
+	defer w.wg.Done()
+
+reconnect:
+	addr := fmt.Sprintf("%s://%s:%d%s", sc.Protocol, sc.Ip, sc.Port, sc.Path)
+	conn := w.dial(addr)
+	if conn == nil {
+		return
+	}
+
+	w.m.Lock()
+	if w.closing {
+		w.m.Unlock()
+		conn.Close()
+		return
+	}
+	w.conns[conn] = struct{}{}
+	w.m.Unlock()
+
+	state := NewSessionState(sc)
+	s := &Session{
+		SessionState: state,
+		conn:         NewWSConn(conn, state),
+		actions:      w.actions,
+	}
+	go s.WriteMsg()
+	s.ReadMsg()
+
+	w.m.Lock()
+	delete(w.conns, conn)
+	w.m.Unlock()
+	s.Close()
+
+	if w.SC.AutoReconnect && !w.isClosing() {
+		time.Sleep(w.SC.ReconnectInterval)
+		goto reconnect
+	}
+}
+
+func (w *WSClient) Update() {
+	for {
+		select {
+		case <-w.closeSign:
+			w.network.ServiceClosed(w.SC)
+		case v := <-w.actions:
+			v.do()
+			w.SC.actionPool.Put(v)
+		default:
+			return
+		}
+	}
+}
+
+func (w *WSClient) Shutdown() {
+	w.m.Lock()
+	w.closing = true
+	for conn := range w.conns {
+		conn.Close()
+	}
+	w.conns = nil
+	w.m.Unlock()
+	w.wg.Wait()
+	close(w.closeSign)
+}
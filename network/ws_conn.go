@@ -0,0 +1,69 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var ErrNotBinary = errors.New("websocket message not binary ")
+
+// WSConn websocket 链接，每个二进制消息是一个完整的应用层数据，不需要包头
+type WSConn struct {
+	*SessionState
+	conn      *websocket.Conn
+	closeOnce sync.Once
+}
+
+func NewWSConn(conn *websocket.Conn, s *SessionState) *WSConn {
+	conn.SetReadLimit(int64(MaxDataLen))
+	if s.SC.IsInnerLink {
+		var timeZero time.Time
+		conn.SetReadDeadline(timeZero)
+		conn.SetWriteDeadline(timeZero)
+	}
+	return &WSConn{
+		SessionState: s,
+		conn:         conn,
+	}
+}
+
+func (w *WSConn) LocalAddr() net.Addr {
+	return w.conn.LocalAddr()
+}
+
+func (w *WSConn) RemoteAddr() net.Addr {
+	return w.conn.RemoteAddr()
+}
+
+// read goroutine
+func (w *WSConn) ReadMsg() ([]byte, uint32, error) {
+	if !w.SC.IsInnerLink && w.SC.ReadTimeout > 0 {
+		w.conn.SetReadDeadline(time.Now().Add(w.SC.ReadTimeout))
+	}
+	mt, data, err := w.conn.ReadMessage()
+	if err != nil {
+		return nil, 0, err
+	}
+	if mt != websocket.BinaryMessage {
+		return nil, 0, ErrNotBinary
+	}
+	return data, 0, nil
+}
+
+// write goroutine
+func (w *WSConn) WriteMsg(data []byte) error {
+	if !w.SC.IsInnerLink && w.SC.WriteTimeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.SC.WriteTimeout))
+	}
+	return w.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (w *WSConn) Close() {
+	w.closeOnce.Do(func() {
+		w.conn.Close()
+	})
+}
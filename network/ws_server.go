@@ -0,0 +1,205 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/skeletongo/core/log"
+)
+
+type WSServer struct {
+	network   *Network
+	SC        *SessionConfig
+	conns     map[*websocket.Conn]struct{}
+	upgrading int          // 正在升级的链接数量，和 conns 一起受 MaxConn 限制
+	actions   chan *action // 消息队列，所有链接接收到的消息都进入这个队列
+	closeSign chan struct{}
+	m         sync.Mutex
+	ln        net.Listener
+	upgrader  websocket.Upgrader
+	certs     *certLoader
+	wgLn      sync.WaitGroup
+	wgConns   sync.WaitGroup
+}
+
+func NewWSServer(n *Network, cfg *SessionConfig) *WSServer {
+	s := &WSServer{
+		network:   n,
+		SC:        cfg,
+		conns:     make(map[*websocket.Conn]struct{}),
+		actions:   make(chan *action, cfg.MaxDone),
+		closeSign: make(chan struct{}),
+	}
+	s.upgrader = websocket.Upgrader{
+		HandshakeTimeout: cfg.ReadTimeout,
+		ReadBufferSize:   cfg.ReadBuffer,
+		WriteBufferSize:  cfg.WriteBuffer,
+		CheckOrigin:      s.checkOrigin,
+	}
+	return s
+}
+
+// checkOrigin 检查请求的 Origin
+// Origins 为空时只允许和 Host 相同的 Origin，包含 "*" 时允许所有
+func (w *WSServer) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if len(w.SC.Origins) == 0 {
+		return strings.EqualFold(u.Host, r.Host)
+	}
+	for _, v := range w.SC.Origins {
+		if v == "*" || strings.EqualFold(v, origin) || strings.EqualFold(v, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *WSServer) Start() error {
+	ln, err := net.Listen("tcp", fmt.Sprintf("%s:%d", w.SC.Ip, w.SC.Port))
+	if err != nil {
+		log.Error("WSServer Listen error:", err)
+		return err
+	}
+	w.ln = ln
+
+	path := w.SC.Path
+	if path == "" {
+		path = "/"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, w.ServeHTTP)
+	// 链接升级后由 WSConn 设置读写超时
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: w.SC.ReadTimeout,
+	}
+	if w.SC.Protocol == "wss" {
+		cfg, certs, err := newServerTLSConfig(w.SC)
+		if err != nil {
+			ln.Close()
+			log.Error("WSServer tls config error:", err)
+			return err
+		}
+		srv.TLSConfig = cfg
+		w.certs = certs
+	}
+
+	w.wgLn.Add(1)
+	go func() {
+		defer w.wgLn.Done()
+		var err error
+		if w.SC.Protocol == "wss" {
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
+		log.Infof("WSServer %s stop: %v", ln.Addr(), err)
+	}()
+	return nil
+}
+
+func (w *WSServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.m.Lock()
+	if w.conns == nil {
+		w.m.Unlock()
+		http.Error(rw, "Service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if len(w.conns)+w.upgrading >= w.SC.MaxConn {
+		w.m.Unlock()
+		http.Error(rw, "Too many connections", http.StatusServiceUnavailable)
+		log.Warn("too many connections")
+		return
+	}
+	// 升级前占用名额，同时进行的握手不会超出 MaxConn
+	w.upgrading++
+	w.wgConns.Add(1)
+	w.m.Unlock()
+	defer w.wgConns.Done()
+
+	conn, err := w.upgrader.Upgrade(rw, r, nil)
+	w.m.Lock()
+	w.upgrading--
+	if err != nil {
+		w.m.Unlock()
+		log.Debugf("upgrade error: %v", err)
+		return
+	}
+	if w.conns == nil {
+		w.m.Unlock()
+		conn.Close()
+		return
+	}
+	w.conns[conn] = struct{}{}
+	w.m.Unlock()
+
+	state := NewSessionState(w.SC)
+	s := &Session{
+		SessionState: state,
+		conn:         NewWSConn(conn, state),
+		actions:      w.actions,
+	}
+	go s.WriteMsg()
+	// http.Server 为每个请求创建一个协程，在这里读取消息
+	s.ReadMsg()
+
+	w.m.Lock()
+	delete(w.conns, conn)
+	w.m.Unlock()
+	s.Close()
+}
+
+// ReloadCert 重新加载 wss 证书，已经建立的链接不受影响
+// 证书文件修改后也会自动重新加载
+func (w *WSServer) ReloadCert() error {
+	if w.certs == nil {
+		return nil
+	}
+	return w.certs.Reload()
+}
+
+func (w *WSServer) Update() {
+	for {
+		select {
+		case <-w.closeSign:
+			w.network.ServiceClosed(w.SC)
+		case v := <-w.actions:
+			v.do()
+			w.SC.actionPool.Put(v)
+		default:
+			return
+		}
+	}
+}
+
+func (w *WSServer) Shutdown() {
+	w.ln.Close()
+	w.wgLn.Wait()
+
+	w.m.Lock()
+	for conn := range w.conns {
+		conn.Close()
+	}
+	w.conns = nil
+	w.m.Unlock()
+	w.wgConns.Wait()
+	close(w.closeSign)
+}
@@ -0,0 +1,86 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type wsMsg struct {
+	Text string
+}
+
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestWSServer(t *testing.T) {
+	SetHandler(2, new(wsMsg), HandlerWrapper(func(s ISession, msgID int, msg interface{}) error {
+		return s.Send(msgID, &wsMsg{Text: "echo:" + msg.(*wsMsg).Text})
+	}))
+
+	port := freePort(t)
+	sc := &SessionConfig{Protocol: "ws", Ip: "127.0.0.1", Port: port, Path: "/ws", MaxConn: 1, MaxDone: 10, MaxSend: 10}
+	sc.Init()
+	srv := NewWSServer(New(), sc)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+				srv.Update()
+			}
+		}
+	}()
+
+	addr := fmt.Sprintf("ws://127.0.0.1:%d/ws", port)
+	// 跨域请求
+	h := http.Header{}
+	h.Set("Origin", "http://example.com")
+	if _, _, err := websocket.DefaultDialer.Dial(addr, h); err == nil {
+		t.Error("1 origin not checked")
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	data, err := Marshal(2, &wsMsg{Text: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+	mt, data, err := conn.ReadMessage()
+	if err != nil || mt != websocket.BinaryMessage {
+		t.Fatal("2", mt, err)
+	}
+	msgID, msg, err := Unmarshal(data)
+	if err != nil || msgID != 2 || msg.(*wsMsg).Text != "echo:hello" {
+		t.Error("3", msgID, msg, err)
+	}
+
+	// 超出最大链接数量
+	if _, _, err = websocket.DefaultDialer.Dial(addr, nil); err == nil {
+		t.Error("4 MaxConn not checked")
+	}
+}
@@ -2,6 +2,7 @@ package task
 
 import (
 	"errors"
+	"time"
 
 	"github.com/skeletongo/core/basic"
 )
@@ -14,16 +15,46 @@ func sendToExecutor(t *Task, name string) {
 		return
 	}
 	Obj.Send(basic.CommandWrapper(func(o *basic.Object) error {
-		w := defaultMaster.getWorker(name)
+		w := defaultMaster.acquire(name)
 		if w == nil {
+			t.releaseLimit()
+			t.complete(nil, ErrCannotFindWorker)
 			return ErrCannotFindWorker
 		}
 
+		// 任务执行结束后取消固定
+		t.executor = name
 		sendCall(w.Object, t)
 		return nil
 	}))
 }
 
+// sendStageToExecutor 在预创建的协程节点中执行任务的后续处理步骤
+func sendStageToExecutor(t *Task, name string, f func(o *basic.Object)) {
+	Obj.Send(basic.CommandWrapper(func(o *basic.Object) error {
+		w := defaultMaster.acquire(name)
+		if w == nil {
+			t.complete(nil, ErrCannotFindWorker)
+			return ErrCannotFindWorker
+		}
+
+		w.Send(basic.CommandWrapper(func(o *basic.Object) error {
+			defer sendRelease(name)
+			f(o)
+			return nil
+		}))
+		return nil
+	}))
+}
+
+// sendRelease 任务名称的一个任务执行结束
+func sendRelease(name string) {
+	Obj.Send(basic.CommandWrapper(func(o *basic.Object) error {
+		defaultMaster.release(name)
+		return nil
+	}))
+}
+
 // sendToFixExecutor 给指定的一个协程节点发送待执行的任务
 func sendToFixExecutor(t *Task, name string) {
 	if t == nil {
@@ -33,8 +64,14 @@ func sendToFixExecutor(t *Task, name string) {
 		w := defaultMaster.getWorkerByName(name)
 		if w == nil {
 			// 创建新的协程节点
-			w = defaultMaster.addWorkerByName(name)
+			w = defaultMaster.addFixWorker(name)
+		}
+		if w == nil {
+			t.releaseLimit()
+			t.complete(nil, ErrTooManyFixWorker)
+			return ErrTooManyFixWorker
 		}
+		defaultMaster.touch(w, time.Now())
 
 		sendCall(w.Object, t)
 		return nil
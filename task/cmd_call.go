@@ -15,8 +15,23 @@ func sendCall(o *basic.Object, t *Task) {
 		log.Errorf("Task [%s] sendCall error: object is nil", t.Name)
 		return
 	}
+	w, ok := o.Data.(*worker)
+	if !ok {
+		o.Send(basic.CommandWrapper(func(o *basic.Object) error {
+			t.run(o)
+			return nil
+		}))
+		return
+	}
+	// 每个任务对应一条消息，消息处理时执行优先级最高的任务
+	w.q.push(t)
 	o.Send(basic.CommandWrapper(func(o *basic.Object) error {
-		t.run(o)
+		if t := w.q.pop(); t != nil {
+			if t.executor != "" {
+				defer sendRelease(t.executor)
+			}
+			t.run(o)
+		}
 		return nil
 	}))
 }
@@ -12,9 +12,15 @@ func sendCallback(o *basic.Object, t *Task) {
 	}
 	if o == nil {
 		log.Errorf("Task [%s] sendCallback error: object is nil", t.Name)
+		return
+	}
+	if o.IsClosed() {
+		return
 	}
-	t.O.Send(basic.CommandWrapper(func(o *basic.Object) error {
-		t.cb.Done(t.ret, t)
+	o.Send(basic.CommandWrapper(func(o *basic.Object) error {
+		t.withTrace(o, func() {
+			t.cb.Done(t.ret, t)
+		})
 		return nil
 	}))
 }
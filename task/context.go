@@ -0,0 +1,123 @@
+package task
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// 任务状态
+const (
+	StatusPending  = iota // 等待执行
+	StatusRunning         // 执行中
+	StatusDone            // 执行成功
+	StatusFailed          // 执行失败
+	StatusCanceled        // 已取消
+	StatusTimeout         // 已超时
+)
+
+// statusOf 根据执行错误获取任务结束时的状态
+func statusOf(err error) int32 {
+	switch err {
+	case nil:
+		return StatusDone
+	case context.Canceled:
+		return StatusCanceled
+	case context.DeadlineExceeded:
+		return StatusTimeout
+	default:
+		return StatusFailed
+	}
+}
+
+// WithContext 设置任务的 context，需要在任务开始前调用
+// ctx 取消或超时后，还没有开始执行的任务不再执行，正在执行的任务可以通过 Task.Context 获取取消信号
+func (t *Task) WithContext(ctx context.Context) *Task {
+	t.ctx, t.cancel = context.WithCancel(ctx)
+	t.watch = true
+	return t
+}
+
+// WithTimeout 设置任务超时时长，从调用时开始计时，需要在任务开始前调用
+func (t *Task) WithTimeout(timeout time.Duration) *Task {
+	t.ctx, t.cancel = context.WithTimeout(t.ctx, timeout)
+	t.watch = true
+	return t
+}
+
+// WithDeadline 设置任务截止时间，需要在任务开始前调用
+func (t *Task) WithDeadline(d time.Time) *Task {
+	t.ctx, t.cancel = context.WithDeadline(t.ctx, d)
+	t.watch = true
+	return t
+}
+
+// Context 获取任务的 context，Callable 中可以用来判断任务是否已经被取消
+func (t *Task) Context() context.Context {
+	return t.ctx
+}
+
+// Cancel 取消任务
+// 还没有开始执行的任务会立即结束，回调方法收到 context.Canceled 错误；
+// 正在执行的任务会收到 context 的取消信号，执行结束后回调方法收到 context.Canceled 错误
+func (t *Task) Cancel() {
+	t.cancel()
+	t.abort()
+}
+
+// Status 获取任务状态
+func (t *Task) Status() int {
+	return int(atomic.LoadInt32(&t.status))
+}
+
+// IsCanceled 任务是否被取消
+func (t *Task) IsCanceled() bool {
+	return t.Status() == StatusCanceled
+}
+
+// IsTimeout 任务是否超时
+func (t *Task) IsTimeout() bool {
+	return t.Status() == StatusTimeout
+}
+
+// abort context 结束后，结束还没有开始执行的任务
+func (t *Task) abort() {
+	err := t.ctx.Err()
+	if err == nil {
+		return
+	}
+	if atomic.CompareAndSwapInt32(&t.status, StatusPending, statusOf(err)) {
+		t.complete(nil, err)
+	}
+}
+
+// begin 任务开始执行前调用，返回 false 时任务不再执行
+func (t *Task) begin() bool {
+	if t.O != nil && t.O.IsClosed() {
+		// 回调节点已经关闭，没有必要再执行
+		t.cancel()
+	}
+	if t.ctx.Err() != nil {
+		t.abort()
+		return false
+	}
+	if !atomic.CompareAndSwapInt32(&t.status, StatusPending, StatusRunning) {
+		return false
+	}
+	atomic.AddInt32(&t.attempts, 1)
+	return true
+}
+
+// startWatch 任务开始后监听 context 的结束信号
+func (t *Task) startWatch() {
+	if !t.watch {
+		return
+	}
+	go func() {
+		select {
+		case <-t.ctx.Done():
+			t.abort()
+		case <-t.fut.Done():
+		}
+	}()
+}
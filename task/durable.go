@@ -0,0 +1,431 @@
+package task
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skeletongo/core/log"
+)
+
+var (
+	ErrNoJournal      = errors.New("Task journal not open ")
+	ErrNotDurable     = errors.New("Task callable not DurableCallable ")
+	ErrDuplicateTask  = errors.New("Task idempotency key duplicate ")
+	ErrUnknownDurable = errors.New("Task durable kind not register ")
+)
+
+// DurableCallable 可以持久化的 Callable
+type DurableCallable interface {
+	Callable
+	// Kind 任务类型，重放时根据类型找到创建方法
+	Kind() string
+	// Marshal 序列化任务数据
+	Marshal() ([]byte, error)
+}
+
+// DurableCreator 根据序列化的任务数据创建 Callable
+type DurableCreator func(data []byte) (Callable, error)
+
+var durableCreators = make(map[string]DurableCreator)
+
+// RegisterDurable 注册持久化任务的创建方法，需要在 task 模块初始化前注册
+func RegisterDurable(kind string, creator DurableCreator) {
+	if creator == nil {
+		return
+	}
+	if _, exist := durableCreators[kind]; exist {
+		panic("repeat register DurableCreator:" + kind)
+	}
+	durableCreators[kind] = creator
+}
+
+// 任务的执行方式
+const (
+	modeGo       = iota // Start
+	modeExecutor        // StartByExecutor
+	modeFix             // StartByFixExecutor
+)
+
+// 日志记录类型
+const (
+	opAdd = "add"
+	opAck = "ack"
+)
+
+// record 日志记录
+type record struct {
+	Op        string
+	ID        uint64
+	Key       string `json:",omitempty"`
+	Name      string `json:",omitempty"`
+	Kind      string `json:",omitempty"`
+	Mode      int    `json:",omitempty"`
+	Executor  string `json:",omitempty"`
+	Priority  int    `json:",omitempty"`
+	NotBefore int64  `json:",omitempty"` // 最早开始执行的时间，单位纳秒
+	Data      []byte `json:",omitempty"`
+}
+
+// durable 持久化任务信息
+type durable struct {
+	id  uint64
+	key string
+}
+
+// DurableConfig 持久化任务配置
+type DurableConfig struct {
+	Path       string // 日志文件路径，为空时不开启
+	Sync       bool   // 每次写入后是否同步到磁盘
+	KeepKeys   int    // 保留的已完成幂等键数量，为0时默认为10000
+	CompactNum int    // 运行期间确认的任务数量达到这个值时压缩日志文件，为0时默认为10000
+}
+
+// journal 只追加写入的任务日志
+// 任务发送前写入 add 记录，结束后写入 ack 记录；启动时重新执行没有 ack 的任务
+// 启动时和运行期间确认的任务数量达到 CompactNum 时压缩日志文件
+type journal struct {
+	sync.Mutex
+	cfg     *DurableConfig
+	f       *os.File
+	w       *bufio.Writer
+	id      uint64
+	keys    map[string]bool    // 幂等键; value:是否已经完成
+	done    *list.List         // 已完成的幂等键，超出保留数量时删除最早的
+	pending map[uint64]*record // 没有确认的任务，压缩时重新写入
+	acked   int                // 上次压缩后确认的任务数量
+}
+
+var defaultJournal *journal
+
+// openJournal 打开日志文件，返回需要重新执行的任务
+func openJournal(cfg *DurableConfig) (*journal, []*record, error) {
+	if cfg.KeepKeys <= 0 {
+		cfg.KeepKeys = 10000
+	}
+	if cfg.CompactNum <= 0 {
+		cfg.CompactNum = 10000
+	}
+	j := &journal{
+		cfg:     cfg,
+		keys:    make(map[string]bool),
+		done:    list.New(),
+		pending: make(map[uint64]*record),
+	}
+
+	pending, err := j.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range pending {
+		j.pending[v.ID] = v
+	}
+	if err = j.compact(); err != nil {
+		return nil, nil, err
+	}
+	return j, pending, nil
+}
+
+// load 读取日志，返回没有 ack 的记录
+func (j *journal) load() ([]*record, error) {
+	f, err := os.Open(j.cfg.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	adds := make(map[uint64]*record)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		r := new(record)
+		if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+			// 最后一条记录可能没有写完整
+			_ = log.Warnf("Task journal %s bad record: %v", j.cfg.Path, err)
+			continue
+		}
+		if r.ID > j.id {
+			j.id = r.ID
+		}
+		switch r.Op {
+		case opAdd:
+			adds[r.ID] = r
+		case opAck:
+			delete(adds, r.ID)
+			if r.Key != "" {
+				j.addDone(r.Key)
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var pending []*record
+	for _, v := range adds {
+		if v.Key != "" {
+			if j.keys[v.Key] {
+				continue
+			}
+			j.keys[v.Key] = false
+		}
+		pending = append(pending, v)
+	}
+	sort.Slice(pending, func(i, k int) bool {
+		return pending[i].ID < pending[k].ID
+	})
+	return pending, nil
+}
+
+// compact 重写日志文件，只保留已完成的幂等键和没有完成的任务
+func (j *journal) compact() error {
+	pending := make([]*record, 0, len(j.pending))
+	for _, v := range j.pending {
+		pending = append(pending, v)
+	}
+	sort.Slice(pending, func(i, k int) bool {
+		return pending[i].ID < pending[k].ID
+	})
+
+	tmp := j.cfg.Path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for e := j.done.Front(); e != nil; e = e.Next() {
+		if err = enc.Encode(&record{Op: opAck, Key: e.Value.(string)}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	for _, v := range pending {
+		if err = enc.Encode(v); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, j.cfg.Path); err != nil {
+		return err
+	}
+
+	// 运行期间压缩时关闭原来的文件，写入的数据都已经 Flush
+	if j.f != nil {
+		j.f.Close()
+	}
+	j.f, err = os.OpenFile(j.cfg.Path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	j.w = bufio.NewWriter(j.f)
+	j.acked = 0
+	return nil
+}
+
+func (j *journal) addDone(key string) {
+	if done, ok := j.keys[key]; ok && done {
+		return
+	}
+	j.keys[key] = true
+	j.done.PushBack(key)
+	for j.done.Len() > j.cfg.KeepKeys {
+		delete(j.keys, j.done.Remove(j.done.Front()).(string))
+	}
+}
+
+func (j *journal) write(r *record) error {
+	if j.f == nil {
+		return ErrNoJournal
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err = j.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err = j.w.Flush(); err != nil {
+		return err
+	}
+	if j.cfg.Sync {
+		return j.f.Sync()
+	}
+	return nil
+}
+
+// add 任务发送前写入日志
+func (j *journal) add(t *Task, mode int, executor string) error {
+	c, ok := t.c.(DurableCallable)
+	if !ok {
+		return ErrNotDurable
+	}
+	data, err := c.Marshal()
+	if err != nil {
+		return err
+	}
+
+	j.Lock()
+	defer j.Unlock()
+	if t.durable.key != "" {
+		if _, exist := j.keys[t.durable.key]; exist {
+			return ErrDuplicateTask
+		}
+	}
+	if t.durable.id == 0 {
+		j.id++
+		t.durable.id = j.id
+	}
+	r := &record{
+		Op:       opAdd,
+		ID:       t.durable.id,
+		Key:      t.durable.key,
+		Name:     t.Name,
+		Kind:     c.Kind(),
+		Mode:     mode,
+		Executor: executor,
+		Priority: t.priority,
+		Data:     data,
+	}
+	if at := t.due(); !at.IsZero() {
+		r.NotBefore = at.UnixNano()
+	}
+	err = j.write(r)
+	if err != nil {
+		return err
+	}
+	j.pending[r.ID] = r
+	if t.durable.key != "" {
+		j.keys[t.durable.key] = false
+	}
+	return nil
+}
+
+// ack 任务结束后写入日志
+// 执行成功时保留幂等键，失败时删除幂等键，允许再次提交
+func (j *journal) ack(t *Task) {
+	j.Lock()
+	defer j.Unlock()
+	r := &record{Op: opAck, ID: t.durable.id}
+	if t.durable.key != "" {
+		if t.err == nil {
+			r.Key = t.durable.key
+			j.addDone(t.durable.key)
+		} else {
+			delete(j.keys, t.durable.key)
+		}
+	}
+	if err := j.write(r); err != nil {
+		_ = log.Errorf("Task [%s] journal ack error: %v", t.Name, err)
+		return
+	}
+	delete(j.pending, r.ID)
+	j.acked++
+	if j.acked < j.cfg.CompactNum {
+		return
+	}
+	if err := j.compact(); err != nil {
+		_ = log.Errorf("Task journal %s compact error: %v", j.cfg.Path, err)
+	}
+}
+
+func (j *journal) close() error {
+	j.Lock()
+	defer j.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
+
+// replay 重新执行没有完成的任务
+func replay(pending []*record) {
+	for _, r := range pending {
+		creator, ok := durableCreators[r.Kind]
+		if !ok {
+			_ = log.Errorf("Task [%s] replay error: %v %s", r.Name, ErrUnknownDurable, r.Kind)
+			continue
+		}
+		c, err := creator(r.Data)
+		if err != nil {
+			_ = log.Errorf("Task [%s] replay error: %v", r.Name, err)
+			continue
+		}
+		t := New(nil, c, nil, r.Name)
+		t.durable = &durable{id: r.ID, key: r.Key}
+		t.replayed = true
+		t.priority = r.Priority
+		if r.NotBefore > 0 {
+			t.notBefore = time.Unix(0, r.NotBefore)
+		}
+		switch r.Mode {
+		case modeExecutor:
+			t.StartByExecutor(r.Executor)
+		case modeFix:
+			t.StartByFixExecutor(r.Executor)
+		default:
+			t.Start()
+		}
+		log.Infof("Task [%s] replay, id: %d, kind: %s", r.Name, r.ID, r.Kind)
+	}
+}
+
+// Durable 设置为持久化任务，需要在任务开始前调用
+// 任务的 Callable 需要实现 DurableCallable，并且通过 RegisterDurable 注册了创建方法
+// 任务发送前写入日志，结束后确认；进程异常退出后，重启时重新执行没有确认的任务，回调方法不会重新执行
+// key 幂等键，相同键的任务执行成功后不会再次执行，返回 ErrDuplicateTask；为空时不检查
+func (t *Task) Durable(key string) *Task {
+	t.durable = &durable{key: key}
+	return t
+}
+
+// IdempotencyKey 持久化任务的幂等键
+func (t *Task) IdempotencyKey() string {
+	if t.durable == nil {
+		return ""
+	}
+	return t.durable.key
+}
+
+// persist 持久化任务发送前写入日志，失败时任务结束
+func (t *Task) persist(mode int, executor string) bool {
+	if t.durable == nil || t.replayed {
+		return true
+	}
+	err := ErrNoJournal
+	if defaultJournal != nil {
+		err = defaultJournal.add(t, mode, executor)
+	}
+	if err != nil {
+		_ = log.Warnf("Task [%s] persist error: %v", t.Name, err)
+		// 写入失败的任务不需要确认
+		t.durable = nil
+		t.complete(nil, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package task
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+)
+
+var durableRun = make(chan int, 10)
+
+type durableCall int
+
+func (d durableCall) Call(o *basic.Object) interface{} {
+	durableRun <- int(d)
+	return nil
+}
+
+func (d durableCall) Kind() string {
+	return "test"
+}
+
+func (d durableCall) Marshal() ([]byte, error) {
+	return []byte(strconv.Itoa(int(d))), nil
+}
+
+func init() {
+	RegisterDurable("test", func(data []byte) (Callable, error) {
+		n, err := strconv.Atoi(string(data))
+		return durableCall(n), err
+	})
+}
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &DurableConfig{Path: filepath.Join(dir, "task.log")}
+
+	j, pending, err := openJournal(cfg)
+	if err != nil || len(pending) != 0 {
+		t.Fatal(err, pending)
+	}
+	defaultJournal = j
+	defer func() {
+		defaultJournal = nil
+	}()
+
+	// 执行成功的任务确认后不会重新执行，相同幂等键的任务不会再次执行
+	_, err = New(Obj, durableCall(1), nil).Durable("a").StartByExecutor("durable").Wait(time.Second)
+	if err != nil || <-durableRun != 1 {
+		t.Error("1", err)
+	}
+	_, err = New(Obj, durableCall(1), nil).Durable("a").StartByExecutor("durable").Wait(time.Second)
+	if err != ErrDuplicateTask {
+		t.Error("2", err)
+	}
+
+	// 模拟进程在任务执行前退出
+	tk := New(Obj, durableCall(2), nil).Durable("b")
+	if err = j.add(tk, modeFix, "durable"); err != nil {
+		t.Fatal(err)
+	}
+	j.close()
+
+	j, pending, err = openJournal(cfg)
+	if err != nil || len(pending) != 1 || pending[0].Key != "b" {
+		t.Fatal(err, pending)
+	}
+	defaultJournal = j
+	replay(pending)
+	select {
+	case n := <-durableRun:
+		if n != 2 {
+			t.Error("3", n)
+		}
+	case <-time.After(time.Second):
+		t.Error("replay timeout")
+	}
+	_, err = New(Obj, durableCall(1), nil).Durable("a").Start().Wait(time.Second)
+	if err != ErrDuplicateTask {
+		t.Error("4", err)
+	}
+	j.close()
+}
+
+func TestJournal_Compact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &DurableConfig{Path: filepath.Join(dir, "task.log"), CompactNum: 5}
+
+	j, _, err := openJournal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 没有完成的任务压缩后保留
+	tk := New(Obj, durableCall(1), nil).Durable("pending")
+	if err = j.add(tk, modeGo, ""); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 12; i++ {
+		tk := New(Obj, durableCall(i), nil).Durable("")
+		if err = j.add(tk, modeGo, ""); err != nil {
+			t.Fatal(err)
+		}
+		j.ack(tk)
+	}
+
+	// 压缩后只剩没有完成的任务和最近确认的任务
+	b, err := ioutil.ReadFile(cfg.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\n"); n != 5 {
+		t.Error("journal not compacted", n)
+	}
+	j.close()
+
+	j, pending, err := openJournal(cfg)
+	if err != nil || len(pending) != 1 || pending[0].Key != "pending" {
+		t.Fatal(err, pending)
+	}
+	j.close()
+}
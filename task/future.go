@@ -0,0 +1,180 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/utils"
+)
+
+var (
+	ErrTimeout = errors.New("Task wait timeout ")
+	ErrNotDone = errors.New("Task not done ")
+)
+
+// PanicError Callable 执行时发生了 panic
+type PanicError struct {
+	Name  string      // 任务名称
+	Value interface{} // recover 的返回值
+	Stack string      // 调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task [%s] panic: %v", e.Name, e.Value)
+}
+
+// Future 任务的执行结果
+type Future struct {
+	sync.Mutex
+	t *Task
+	// done 任务执行结束后关闭
+	done chan struct{}
+	ret  interface{}
+	err  error
+	// listeners 任务执行结束后调用的方法
+	listeners []func(f *Future)
+}
+
+func newFuture(t *Task) *Future {
+	return &Future{
+		t:    t,
+		done: make(chan struct{}),
+	}
+}
+
+// Task 获取任务
+func (f *Future) Task() *Task {
+	return f.t
+}
+
+// Done 任务执行结束后返回的 chan 会被关闭
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// IsDone 任务是否已经执行结束
+func (f *Future) IsDone() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Result 获取任务执行结果，不会阻塞
+// 任务没有执行结束时返回 ErrNotDone
+func (f *Future) Result() (ret interface{}, err error) {
+	if !f.IsDone() {
+		return nil, ErrNotDone
+	}
+	return f.ret, f.err
+}
+
+// Wait 等待任务执行结束，会阻塞当前协程，不要在节点协程中调用
+// timeout 最长等待时长，小于等于0时一直等待
+// 超时返回 ErrTimeout
+func (f *Future) Wait(timeout time.Duration) (ret interface{}, err error) {
+	if timeout <= 0 {
+		<-f.done
+		return f.ret, f.err
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-f.done:
+		return f.ret, f.err
+	case <-t.C:
+		return nil, ErrTimeout
+	}
+}
+
+// complete 设置执行结果，只有第一次调用有效
+func (f *Future) complete(ret interface{}, err error) bool {
+	f.Lock()
+	if f.IsDone() {
+		f.Unlock()
+		return false
+	}
+	f.ret = ret
+	f.err = err
+	close(f.done)
+	listeners := f.listeners
+	f.listeners = nil
+	f.Unlock()
+
+	for _, v := range listeners {
+		v(f)
+	}
+	return true
+}
+
+// onDone 添加任务执行结束后调用的方法，任务已经结束时直接调用
+// 方法在结束任务的协程中执行
+func (f *Future) onDone(fn func(f *Future)) {
+	f.Lock()
+	if !f.IsDone() {
+		f.listeners = append(f.listeners, fn)
+		f.Unlock()
+		return
+	}
+	f.Unlock()
+	fn(f)
+}
+
+// Await 等待多个任务全部执行结束后在节点 o 中执行回调方法，不会阻塞节点协程
+// o 回调方法执行节点，为nil时在默认节点上执行；没有设置默认节点时，在最后一个结束的任务的协程中直接执行
+// cb 回调方法，参数顺序和 fs 相同
+func Await(o *basic.Object, cb func(fs []*Future), fs ...*Future) {
+	if o == nil {
+		o = defaultObject
+	}
+	if o == nil {
+		awaitDirect(cb, fs)
+		return
+	}
+	n := len(fs)
+	if n == 0 {
+		o.Send(basic.CommandWrapper(func(o *basic.Object) error {
+			cb(fs)
+			return nil
+		}))
+		return
+	}
+	for _, f := range fs {
+		f.onDone(func(*Future) {
+			o.Send(basic.CommandWrapper(func(o *basic.Object) error {
+				// 计数只在节点 o 中修改，不需要加锁
+				n--
+				if n == 0 {
+					cb(fs)
+				}
+				return nil
+			}))
+		})
+	}
+}
+
+// awaitDirect 所有任务执行结束后直接执行回调方法
+func awaitDirect(cb func(fs []*Future), fs []*Future) {
+	call := func() {
+		defer utils.DumpStackIfPanic("Task.Await")
+		cb(fs)
+	}
+	n := int32(len(fs))
+	if n == 0 {
+		call()
+		return
+	}
+	for _, f := range fs {
+		f.onDone(func(*Future) {
+			if atomic.AddInt32(&n, -1) == 0 {
+				call()
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package task
+
+import (
+	"sync"
+)
+
+// defaultGoPool Task.Start 使用的协程池
+var defaultGoPool = newGoPool()
+
+// goPool 限制 Task.Start 同时运行的协程数量
+// 超出数量的任务按优先级排队等待，由正在运行的协程执行完当前任务后继续执行，不会阻塞调用方
+type goPool struct {
+	sync.Mutex
+	// max 最大协程数量，为0时不限制
+	max int
+	// running 正在运行的协程数量
+	running int
+	// pending 等待执行的任务
+	pending *taskQueue
+}
+
+func newGoPool() *goPool {
+	return &goPool{
+		pending: newTaskQueue(),
+	}
+}
+
+func (p *goPool) setMax(n int) {
+	p.Lock()
+	p.max = n
+	p.Unlock()
+}
+
+func (p *goPool) run(t *Task) {
+	p.Lock()
+	if p.max > 0 && p.running >= p.max {
+		p.pending.push(t)
+		p.Unlock()
+		return
+	}
+	p.running++
+	p.Unlock()
+	go p.loop(t)
+}
+
+func (p *goPool) loop(t *Task) {
+	for t != nil {
+		t.run(nil)
+
+		p.Lock()
+		if t = p.pending.pop(); t == nil {
+			p.running--
+		}
+		p.Unlock()
+	}
+}
+
+// state 正在运行的协程数量和排队等待的任务数量
+func (p *goPool) state() (running, pending int) {
+	p.Lock()
+	defer p.Unlock()
+	return p.running, p.pending.Len()
+}
@@ -0,0 +1,42 @@
+package task
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+)
+
+func TestGoPool(t *testing.T) {
+	p := newGoPool()
+	p.setMax(2)
+
+	var running, max int32
+	var fs []*Future
+	for i := 0; i < 6; i++ {
+		tk := New(basic.Root, CallableWrapper(func(o *basic.Object) interface{} {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond * 10)
+			atomic.AddInt32(&running, -1)
+			return nil
+		}), nil)
+		fs = append(fs, tk.Future())
+		p.run(tk)
+	}
+	for _, f := range fs {
+		f.Wait(time.Second)
+	}
+	if max != 2 {
+		t.Error(max)
+	}
+	if running, pending := p.state(); running != 0 || pending != 0 {
+		t.Error(running, pending)
+	}
+}
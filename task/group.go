@@ -0,0 +1,219 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/skeletongo/core/basic"
+)
+
+// 任务组结束条件
+const (
+	WaitAll        = iota // 所有任务执行结束
+	WaitAny               // 任意一个任务执行成功，或所有任务执行失败
+	WaitFirstError        // 所有任务执行成功，或任意一个任务执行失败
+)
+
+type GroupNotify interface {
+	// Done 任务组回调方法
+	// g 任务组，执行结果通过 g.Results 和 g.Errors 获取
+	Done(g *Group)
+}
+
+type GroupNotifyWrapper func(g *Group)
+
+func (gnw GroupNotifyWrapper) Done(g *Group) {
+	gnw(g)
+}
+
+// Group 任务组，同时执行多个任务，满足结束条件后在节点 O 中执行一次回调方法，并取消还没有结束的任务
+// O 为nil时回调方法在最后一个结束的任务的协程中执行；零值可以直接使用
+type Group struct {
+	O      *basic.Object      // 回调方法执行节点
+	Name   string             // 任务组名称
+	mode   int                // 结束条件
+	cb     GroupNotify        // 回调方法
+	tasks  []*Task            // 所有任务
+	ctx    context.Context    // 所有任务的 context
+	cancel context.CancelFunc // 取消所有任务
+	mu     sync.Mutex         // O 为nil时保护以下字段
+
+	// 以下字段只在节点 O 中修改
+	remain int   // 还没有结束的任务数量
+	done   bool  // 任务组是否已经结束
+	winner int   // WaitAny 时第一个执行成功的任务序号
+	err    error // 任务组执行错误
+}
+
+// NewGroup 创建任务组
+// o 回调方法执行的节点，为nil时在默认节点上执行
+// mode 结束条件 WaitAll,WaitAny,WaitFirstError
+// cb 回调方法
+// name 任务组名称
+func NewGroup(o *basic.Object, mode int, cb GroupNotify, name ...string) *Group {
+	g := &Group{
+		O:      o,
+		mode:   mode,
+		cb:     cb,
+		winner: -1,
+	}
+	if len(name) > 0 {
+		g.Name = name[0]
+	}
+	if o == nil {
+		g.O = defaultObject
+	}
+	g.init()
+	return g
+}
+
+// init 初始化 context，零值的任务组第一次使用时初始化
+func (g *Group) init() {
+	if g.ctx != nil {
+		return
+	}
+	g.winner = -1
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+}
+
+// post 在节点 O 中执行 f，O 为nil时加锁后在当前协程中执行
+func (g *Group) post(f func()) {
+	if g.O == nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		f()
+		return
+	}
+	g.O.Send(basic.CommandWrapper(func(o *basic.Object) error {
+		f()
+		return nil
+	}))
+}
+
+// Add 添加任务，需要在任务组开始前调用
+// c 需要并发执行的方法
+// name 任务名称，默认为 任务组名称/序号
+// 返回任务，可以用来设置超时、重试等
+func (g *Group) Add(c Callable, name ...string) *Task {
+	g.init()
+	n := fmt.Sprintf("%s/%d", g.Name, len(g.tasks))
+	if len(name) > 0 {
+		n = name[0]
+	}
+	t := New(g.O, c, nil, n).WithContext(g.ctx)
+	g.tasks = append(g.tasks, t)
+	return t
+}
+
+// Start 每个任务创建一个协程去执行
+func (g *Group) Start() {
+	g.start(func(i int, t *Task) *Future {
+		return t.Start()
+	})
+}
+
+// StartByExecutor 在预创建的协程节点中执行
+// name 执行节点名称前缀，每个任务使用 name/序号 选择协程节点
+func (g *Group) StartByExecutor(name string) {
+	g.start(func(i int, t *Task) *Future {
+		return t.StartByExecutor(fmt.Sprintf("%s/%d", name, i))
+	})
+}
+
+func (g *Group) start(f func(i int, t *Task) *Future) {
+	g.init()
+	g.remain = len(g.tasks)
+	if g.remain == 0 {
+		g.post(func() {
+			g.finish(nil)
+		})
+		return
+	}
+	for i, t := range g.tasks {
+		i := i
+		f(i, t).onDone(func(f *Future) {
+			g.post(func() {
+				g.taskDone(i, f.err)
+			})
+		})
+	}
+}
+
+// taskDone 一个任务执行结束，在节点 O 中执行
+func (g *Group) taskDone(i int, err error) {
+	if g.done {
+		return
+	}
+	g.remain--
+	switch g.mode {
+	case WaitAny:
+		if err == nil {
+			g.winner = i
+			g.finish(nil)
+			return
+		}
+	case WaitFirstError:
+		if err != nil {
+			g.finish(err)
+			return
+		}
+	}
+	if g.remain == 0 {
+		g.finish(err)
+	}
+}
+
+// finish 任务组结束，取消还没有结束的任务并执行回调方法
+func (g *Group) finish(err error) {
+	g.done = true
+	if g.mode != WaitAll {
+		g.err = err
+	}
+	g.cancel()
+	if g.cb != nil {
+		g.cb.Done(g)
+	}
+}
+
+// Cancel 取消任务组中所有任务
+func (g *Group) Cancel() {
+	g.init()
+	g.cancel()
+}
+
+// Tasks 获取所有任务
+func (g *Group) Tasks() []*Task {
+	return g.tasks
+}
+
+// Results 所有任务的返回值，顺序和添加顺序相同，没有结束的任务为nil
+func (g *Group) Results() []interface{} {
+	ret := make([]interface{}, len(g.tasks))
+	for i, t := range g.tasks {
+		ret[i], _ = t.fut.Result()
+	}
+	return ret
+}
+
+// Errors 所有任务的执行错误，顺序和添加顺序相同，没有结束的任务为 ErrNotDone
+func (g *Group) Errors() []error {
+	ret := make([]error, len(g.tasks))
+	for i, t := range g.tasks {
+		_, ret[i] = t.fut.Result()
+	}
+	return ret
+}
+
+// Err 任务组执行错误
+// WaitAll 总是返回nil，需要通过 Errors 获取每个任务的执行错误
+// WaitAny 所有任务都执行失败时返回最后一个执行错误
+// WaitFirstError 返回第一个执行错误
+func (g *Group) Err() error {
+	return g.err
+}
+
+// Winner WaitAny 时第一个执行成功的任务序号，没有时返回-1
+func (g *Group) Winner() int {
+	return g.winner
+}
@@ -14,13 +14,22 @@ var Obj *basic.Object
 var Config = new(Configuration)
 
 type WorkerConfig struct {
-	Options   *basic.Options // 协程节点配置
-	WorkerCnt int            // 预创建的协程数量
+	Options        *basic.Options // 协程节点配置
+	WorkerCnt      int            // 预创建的协程数量
+	MinWorker      int            // 最少预创建的协程数量，为0时等于 WorkerCnt
+	MaxWorker      int            // 最多预创建的协程数量，为0时等于 WorkerCnt，即不扩容
+	GrowQueueLen   int            // 协程节点平均待处理任务数达到这个值时扩容，为0时默认为8
+	IdleTimeout    time.Duration  // 协程节点空闲多久后回收，单位秒，为0时不回收
+	MaxGo          int            // Start 方法同时运行的最大协程数量，为0时不限制
+	MaxFixWorker   int            // StartByFixExecutor 最多创建的协程数量，为0时不限制
+	FixIdleTimeout time.Duration  // StartByFixExecutor 创建的协程空闲多久后回收，单位秒，为0时不回收
 }
 
 type Configuration struct {
-	Options *basic.Options // 协程管理节点配置
-	Worker  *WorkerConfig  // 协程节点配置
+	Options *basic.Options    // 协程管理节点配置
+	Worker  *WorkerConfig     // 协程节点配置
+	Limits  map[string]*Limit // 协程节点名称的限流配置
+	Durable *DurableConfig    // 持久化任务配置
 }
 
 func (c *Configuration) Name() string {
@@ -36,14 +45,52 @@ func (c *Configuration) Init() error {
 	if c.Worker.WorkerCnt <= 0 {
 		c.Worker.WorkerCnt = 4
 	}
-	Obj = basic.NewObject(basic.TaskID, "task", c.Options, nil)
-	Obj.Run()
+	if c.Worker.MinWorker <= 0 {
+		c.Worker.MinWorker = c.Worker.WorkerCnt
+	}
+	if c.Worker.MaxWorker <= 0 {
+		c.Worker.MaxWorker = c.Worker.WorkerCnt
+	}
+	if c.Worker.MaxWorker < c.Worker.MinWorker {
+		c.Worker.MaxWorker = c.Worker.MinWorker
+	}
+	if c.Worker.WorkerCnt < c.Worker.MinWorker {
+		c.Worker.WorkerCnt = c.Worker.MinWorker
+	}
+	if c.Worker.WorkerCnt > c.Worker.MaxWorker {
+		c.Worker.WorkerCnt = c.Worker.MaxWorker
+	}
+	if c.Worker.GrowQueueLen <= 0 {
+		c.Worker.GrowQueueLen = 8
+	}
+	c.Worker.IdleTimeout *= time.Second
+	c.Worker.FixIdleTimeout *= time.Second
+	defaultGoPool.setMax(c.Worker.MaxGo)
+	for name, l := range c.Limits {
+		SetLimit(name, l)
+	}
+	Obj = basic.NewObject(basic.TaskID, "task", c.Options, new(sink))
 	// 预创建协程节点，并连接到 Obj 节点，作为子节点
-	defaultMaster = newMaster(c.Worker.WorkerCnt)
+	// 在 Obj 启动前创建，之后只在 Obj 节点中使用
+	defaultMaster = newMaster(c.Worker)
+	Obj.Run()
+
+	// 重新执行上次没有完成的持久化任务
+	if c.Durable != nil && c.Durable.Path != "" {
+		j, pending, err := openJournal(c.Durable)
+		if err != nil {
+			return err
+		}
+		defaultJournal = j
+		replay(pending)
+	}
 	return nil
 }
 
 func (c *Configuration) Close() error {
+	if defaultJournal != nil {
+		return defaultJournal.close()
+	}
 	return nil
 }
 
@@ -0,0 +1,241 @@
+package task
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Limit 协程节点名称的限流配置，作用于 StartByExecutor 和 StartByFixExecutor 使用相同名称的任务
+// 超出限制的任务按顺序排队等待，不会失败
+type Limit struct {
+	Rate        float64 // 每秒允许开始执行的任务数量，为0时不限制
+	Burst       int     // 令牌桶容量，小于1时为1
+	MaxInFlight int     // 同时执行的最大任务数量，为0时不限制
+}
+
+// LimitState 限流状态
+type LimitState struct {
+	Name       string        // 协程节点名称
+	Waiting    int           // 排队等待的任务数量
+	InFlight   int           // 正在执行的任务数量
+	Passed     uint64        // 已经开始执行的任务数量
+	Throttled  uint64        // 排队等待过的任务数量
+	DelayTotal time.Duration // 排队等待总时长
+	DelayMax   time.Duration // 最长排队等待时长
+}
+
+type waiting struct {
+	t        *Task
+	dispatch func()
+	enqueue  time.Time
+}
+
+// limiter 令牌桶限流和并发数限制
+type limiter struct {
+	sync.Mutex
+	cfg    Limit
+	tokens float64
+	last   time.Time
+	// timer 等待令牌的定时器
+	timer *time.Timer
+	queue *list.List
+	state LimitState
+	// pumping 是否有协程正在发送任务
+	pumping bool
+	// again 发送期间有新的调用，需要再检查一次
+	again bool
+}
+
+func newLimiter(name string, cfg *Limit) *limiter {
+	l := &limiter{
+		queue: list.New(),
+		last:  time.Now(),
+	}
+	l.state.Name = name
+	l.setConfig(cfg)
+	l.tokens = float64(l.cfg.Burst)
+	return l
+}
+
+func (l *limiter) setConfig(cfg *Limit) {
+	l.cfg = *cfg
+	if l.cfg.Burst < 1 {
+		l.cfg.Burst = 1
+	}
+}
+
+// refill 补充令牌
+func (l *limiter) refill(now time.Time) {
+	if l.cfg.Rate <= 0 {
+		return
+	}
+	l.tokens += now.Sub(l.last).Seconds() * l.cfg.Rate
+	if max := float64(l.cfg.Burst); l.tokens > max {
+		l.tokens = max
+	}
+	l.last = now
+}
+
+// add 任务排队等待执行
+func (l *limiter) add(t *Task, dispatch func()) {
+	l.Lock()
+	l.queue.PushBack(&waiting{t: t, dispatch: dispatch, enqueue: time.Now()})
+	l.Unlock()
+	l.pump()
+}
+
+// done 一个任务执行结束
+func (l *limiter) done() {
+	l.Lock()
+	if l.state.InFlight > 0 {
+		l.state.InFlight--
+	}
+	l.Unlock()
+	l.pump()
+}
+
+// pump 按顺序发送满足限制条件的任务
+// 同时只有一个协程发送任务，其它协程调用时通知正在发送的协程再检查一次，保证任务按排队顺序发送
+func (l *limiter) pump() {
+	l.Lock()
+	if l.pumping {
+		l.again = true
+		l.Unlock()
+		return
+	}
+	l.pumping = true
+	for {
+		l.again = false
+		ready := l.take(time.Now())
+		l.Unlock()
+
+		for _, v := range ready {
+			v.dispatch()
+		}
+
+		l.Lock()
+		if !l.again {
+			break
+		}
+	}
+	l.pumping = false
+	l.Unlock()
+}
+
+// take 按顺序取出满足限制条件的任务，需要加锁调用
+func (l *limiter) take(now time.Time) []*waiting {
+	var ready []*waiting
+	l.refill(now)
+	for e := l.queue.Front(); e != nil; e = l.queue.Front() {
+		if l.cfg.MaxInFlight > 0 && l.state.InFlight >= l.cfg.MaxInFlight {
+			break
+		}
+		if l.cfg.Rate > 0 {
+			if l.tokens < 1 {
+				if l.timer == nil {
+					d := time.Duration((1 - l.tokens) / l.cfg.Rate * float64(time.Second))
+					l.timer = time.AfterFunc(d, func() {
+						l.Lock()
+						l.timer = nil
+						l.Unlock()
+						l.pump()
+					})
+				}
+				break
+			}
+			l.tokens--
+		}
+		w := l.queue.Remove(e).(*waiting)
+		l.state.InFlight++
+		l.state.Passed++
+		if delay := now.Sub(w.enqueue); delay > time.Millisecond {
+			l.state.Throttled++
+			l.state.DelayTotal += delay
+			if delay > l.state.DelayMax {
+				l.state.DelayMax = delay
+			}
+		}
+		w.t.limit = l
+		ready = append(ready, w)
+	}
+	return ready
+}
+
+func (l *limiter) getState() *LimitState {
+	l.Lock()
+	defer l.Unlock()
+	st := l.state
+	st.Waiting = l.queue.Len()
+	return &st
+}
+
+var limiters = struct {
+	sync.RWMutex
+	m map[string]*limiter
+}{m: make(map[string]*limiter)}
+
+// SetLimit 设置协程节点名称的限流配置
+// name 协程节点名称，和 StartByExecutor、StartByFixExecutor 的参数相同
+// l 限流配置，为nil时取消限流，已经排队的任务继续按原配置执行
+func SetLimit(name string, l *Limit) {
+	limiters.Lock()
+	old := limiters.m[name]
+	if l == nil {
+		delete(limiters.m, name)
+		limiters.Unlock()
+		return
+	}
+	if old == nil {
+		limiters.m[name] = newLimiter(name, l)
+		limiters.Unlock()
+		return
+	}
+	limiters.Unlock()
+
+	old.Lock()
+	old.setConfig(l)
+	old.Unlock()
+	old.pump()
+}
+
+func getLimiter(name string) *limiter {
+	limiters.RLock()
+	defer limiters.RUnlock()
+	return limiters.m[name]
+}
+
+// GetLimitStates 获取所有限流状态，按名称排序
+func GetLimitStates() []*LimitState {
+	limiters.RLock()
+	var ret []*LimitState
+	for _, v := range limiters.m {
+		ret = append(ret, v.getState())
+	}
+	limiters.RUnlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// limitDispatch 根据限流配置发送任务
+func limitDispatch(t *Task, name string, dispatch func()) {
+	l := getLimiter(name)
+	if l == nil {
+		dispatch()
+		return
+	}
+	l.add(t, dispatch)
+}
+
+// releaseLimit 任务的一次执行结束，释放限流占用的并发数
+func (t *Task) releaseLimit() {
+	l := t.limit
+	t.limit = nil
+	if l != nil {
+		l.done()
+	}
+}
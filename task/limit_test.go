@@ -0,0 +1,46 @@
+package task
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Order(t *testing.T) {
+	const inFlight, n = 50, 2000
+	l := newLimiter("order", &Limit{MaxInFlight: inFlight})
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	wg.Add(n)
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		i := i
+		l.add(&Task{}, func() {
+			// 发送比较慢时，其它协程有机会同时发送
+			time.Sleep(time.Microsecond)
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			// 先占满并发数，之后同时结束，多个协程同时发送排队的任务
+			go func() {
+				if i < inFlight {
+					<-start
+				}
+				l.done()
+				wg.Done()
+			}()
+		})
+	}
+	close(start)
+	wg.Wait()
+
+	if len(order) != n {
+		t.Fatal("dispatched", len(order))
+	}
+	for i, v := range order {
+		if v != i {
+			t.Fatal("out of order", i, v)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package task
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/utils"
+)
+
+// goExecutor Start 方法执行任务时的协程节点名称
+const goExecutor = "go"
+
+// MaxMetrics 按任务名称统计的最大数量，超出时删除最久没有更新的统计，为0时不限制
+var MaxMetrics = 1000
+
+// Metric 任务执行统计
+type Metric struct {
+	Name      string          // 协程节点名称或任务名称
+	Submitted uint64          // 提交的任务数量，只统计任务名称
+	Canceled  uint64          // 取消或超时的任务数量，只统计任务名称
+	Started   uint64          // 开始执行的次数，包含重试
+	Running   int64           // 正在执行的数量
+	Succeeded uint64          // 执行成功的次数
+	Failed    uint64          // 执行失败的次数，不包含 panic
+	Panicked  uint64          // 执行时发生 panic 的次数
+	Queued    utils.Histogram // 排队时长
+	Run       utils.Histogram // 执行时长
+}
+
+func newMetric(name string) *Metric {
+	return &Metric{
+		Name: name,
+	}
+}
+
+func (m *Metric) begin(queued time.Duration) {
+	m.Started++
+	m.Running++
+	m.Queued.Add(queued)
+}
+
+func (m *Metric) end(cost time.Duration, err error) {
+	m.Running--
+	m.Run.Add(cost)
+	switch err.(type) {
+	case nil:
+		m.Succeeded++
+	case *PanicError:
+		m.Panicked++
+	default:
+		m.Failed++
+	}
+}
+
+func (m *Metric) clone() *Metric {
+	c := *m
+	c.Queued = m.Queued.Clone()
+	c.Run = m.Run.Clone()
+	return &c
+}
+
+// Metrics 所有任务执行统计
+type Metrics struct {
+	Executors map[string]*Metric // key:协程节点名称，Start 方法为 "go"
+	Names     map[string]*Metric // key:任务名称，最多保留 MaxMetrics 个
+}
+
+// metricTable 统计表，数量超出 max 时删除最久没有更新的统计
+type metricTable struct {
+	max func() int
+	m   map[string]*list.Element
+	l   *list.List
+}
+
+func newMetricTable(max func() int) *metricTable {
+	return &metricTable{
+		max: max,
+		m:   make(map[string]*list.Element),
+		l:   list.New(),
+	}
+}
+
+func (t *metricTable) get(name string) *Metric {
+	if e, ok := t.m[name]; ok {
+		t.l.MoveToFront(e)
+		return e.Value.(*Metric)
+	}
+	v := newMetric(name)
+	t.m[name] = t.l.PushFront(v)
+	if max := t.max(); max > 0 {
+		for t.l.Len() > max {
+			delete(t.m, t.l.Remove(t.l.Back()).(*Metric).Name)
+		}
+	}
+	return v
+}
+
+func (t *metricTable) remove(name string) {
+	if e, ok := t.m[name]; ok {
+		t.l.Remove(e)
+		delete(t.m, name)
+	}
+}
+
+func (t *metricTable) clone() map[string]*Metric {
+	ret := make(map[string]*Metric, len(t.m))
+	for k, e := range t.m {
+		ret[k] = e.Value.(*Metric).clone()
+	}
+	return ret
+}
+
+// metrics 协程节点的统计在节点回收时删除，任务名称的统计数量不超过 MaxMetrics
+var metrics = struct {
+	sync.Mutex
+	executors *metricTable
+	names     *metricTable
+}{
+	executors: newMetricTable(func() int { return 0 }),
+	names:     newMetricTable(func() int { return MaxMetrics }),
+}
+
+// removeMetric 删除协程节点的统计，在协程节点回收时调用
+func removeMetric(executor string) {
+	metrics.Lock()
+	metrics.executors.remove(executor)
+	metrics.Unlock()
+}
+
+func executorName(o *basic.Object) string {
+	if o == nil {
+		return goExecutor
+	}
+	return o.Name
+}
+
+// onSubmit 任务提交
+func (t *Task) onSubmit() {
+	metrics.Lock()
+	metrics.names.get(t.Name).Submitted++
+	metrics.Unlock()
+}
+
+// onBegin 任务开始执行
+func (t *Task) onBegin(o *basic.Object, now time.Time) {
+	queued := now.Sub(t.queuedAt)
+	metrics.Lock()
+	metrics.executors.get(executorName(o)).begin(queued)
+	metrics.names.get(t.Name).begin(queued)
+	metrics.Unlock()
+}
+
+// onEnd 任务执行结束
+func (t *Task) onEnd(o *basic.Object, start time.Time, err error) {
+	cost := time.Since(start)
+	metrics.Lock()
+	metrics.executors.get(executorName(o)).end(cost, err)
+	metrics.names.get(t.Name).end(cost, err)
+	metrics.Unlock()
+}
+
+// onCanceled 任务取消或超时
+func (t *Task) onCanceled() {
+	metrics.Lock()
+	metrics.names.get(t.Name).Canceled++
+	metrics.Unlock()
+}
+
+// GetMetrics 获取任务执行统计
+func GetMetrics() *Metrics {
+	metrics.Lock()
+	defer metrics.Unlock()
+	return &Metrics{
+		Executors: metrics.executors.clone(),
+		Names:     metrics.names.clone(),
+	}
+}
+
+// WithTraceID 设置任务的追踪ID，需要在任务开始前调用
+// 默认使用创建任务时回调方法执行节点的追踪ID，没有时使用任务 context 中的追踪ID
+func (t *Task) WithTraceID(id string) *Task {
+	t.traceID = id
+	return t
+}
+
+// TraceID 任务的追踪ID
+// Callable 和回调方法执行期间，执行节点的 TraceID 也是这个值
+// Start 执行的任务 Callable 的参数 o 为nil，需要通过 Task.TraceID 或 TraceIDFromContext(Task.Context()) 获取
+func (t *Task) TraceID() string {
+	return t.traceID
+}
+
+type traceKey struct{}
+
+// TraceIDFromContext 获取 context 中的追踪ID，任务开始后任务的 context 中包含任务的追踪ID
+func TraceIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(traceKey{}).(string)
+	return id
+}
+
+// bindTrace 任务开始时把追踪ID放到任务的 context 中，子任务使用这个 context 时继承追踪ID
+func (t *Task) bindTrace() {
+	if t.traceID == "" {
+		t.traceID = TraceIDFromContext(t.ctx)
+	}
+	if t.traceID != "" && TraceIDFromContext(t.ctx) != t.traceID {
+		t.ctx = context.WithValue(t.ctx, traceKey{}, t.traceID)
+	}
+}
+
+// withTrace 在节点 o 中执行 f 期间设置节点的追踪ID
+func (t *Task) withTrace(o *basic.Object, f func()) {
+	if o == nil || t.traceID == "" {
+		f()
+		return
+	}
+	prev := o.SetTraceID(t.traceID)
+	defer o.SetTraceID(prev)
+	f()
+}
+
+// send 发送任务到执行节点
+func (t *Task) send() {
+	t.queuedAt = time.Now()
+	t.dispatch()
+}
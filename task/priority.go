@@ -0,0 +1,129 @@
+package task
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// 任务优先级，值越大越优先执行
+const (
+	PriorityLow    = -1 // 低优先级
+	PriorityNormal = 0  // 默认优先级
+	PriorityHigh   = 1  // 高优先级
+	PriorityUrgent = 2  // 紧急
+)
+
+// WithPriority 设置任务优先级，需要在任务开始前调用
+// 同一个协程节点中等待执行的任务，优先级高的先执行，优先级相同的按发送顺序执行
+func (t *Task) WithPriority(priority int) *Task {
+	t.priority = priority
+	return t
+}
+
+// Priority 任务优先级
+func (t *Task) Priority() int {
+	return t.priority
+}
+
+// NotBefore 设置任务最早开始执行的时间，需要在任务开始前调用
+// 在时间到达前任务不会发送到协程节点，不占用协程
+func (t *Task) NotBefore(at time.Time) *Task {
+	t.notBefore = at
+	return t
+}
+
+// Delay 设置任务延迟执行的时长，从任务开始时计时，需要在任务开始前调用
+func (t *Task) Delay(d time.Duration) *Task {
+	t.delay = d
+	return t
+}
+
+// due 计算任务最早开始执行的时间，Delay 从第一次调用时开始计时
+func (t *Task) due() time.Time {
+	if t.delay > 0 {
+		if at := time.Now().Add(t.delay); at.After(t.notBefore) {
+			t.notBefore = at
+		}
+		t.delay = 0
+	}
+	return t.notBefore
+}
+
+// schedule 延时任务等待到达执行时间后再发送
+func (t *Task) schedule() {
+	if d := time.Until(t.due()); d > 0 {
+		time.AfterFunc(d, t.send)
+		return
+	}
+	t.send()
+}
+
+type taskItem struct {
+	t   *Task
+	seq uint64
+}
+
+type taskHeap []*taskItem
+
+func (h taskHeap) Len() int {
+	return len(h)
+}
+
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].t.priority != h[j].t.priority {
+		return h[i].t.priority > h[j].t.priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *taskHeap) Push(x interface{}) {
+	*h = append(*h, x.(*taskItem))
+}
+
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return x
+}
+
+// taskQueue 按优先级排序的任务队列
+type taskQueue struct {
+	sync.Mutex
+	h   taskHeap
+	seq uint64
+}
+
+func newTaskQueue() *taskQueue {
+	return new(taskQueue)
+}
+
+func (q *taskQueue) Len() int {
+	q.Lock()
+	defer q.Unlock()
+	return q.h.Len()
+}
+
+func (q *taskQueue) push(t *Task) {
+	q.Lock()
+	q.seq++
+	heap.Push(&q.h, &taskItem{t: t, seq: q.seq})
+	q.Unlock()
+}
+
+// pop 取出优先级最高的任务，没有任务时返回nil
+func (q *taskQueue) pop() *Task {
+	q.Lock()
+	defer q.Unlock()
+	if q.h.Len() == 0 {
+		return nil
+	}
+	return heap.Pop(&q.h).(*taskItem).t
+}
@@ -0,0 +1,94 @@
+package task
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy 任务重试策略
+// 任务执行失败后等待一段时间重新发送到原来的执行方式中执行，等待期间不占用协程节点
+type RetryPolicy struct {
+	// MaxAttempts 最多执行次数，包含第一次执行；小于等于1时不重试
+	MaxAttempts int
+	// Delay 第一次重试前的等待时长
+	Delay time.Duration
+	// MaxDelay 最长等待时长，为0时不限制
+	MaxDelay time.Duration
+	// Multiplier 每次重试等待时长的增长倍数，小于1时为2
+	Multiplier float64
+	// Jitter 等待时长的随机抖动比例，取值范围[0,1]；例如0.2表示在等待时长的 ±20% 内随机
+	Jitter float64
+	// Retryable 判断错误是否可以重试，为nil时除了任务被取消和超时，其它错误都重试
+	Retryable func(err error) bool
+}
+
+// retryable 判断第 attempts 次执行失败后是否可以重试
+func (p *RetryPolicy) retryable(attempts int, err error) bool {
+	if err == nil || attempts >= p.MaxAttempts {
+		return false
+	}
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return true
+}
+
+// delay 第 attempts 次执行失败后的等待时长
+func (p *RetryPolicy) delay(attempts int) time.Duration {
+	m := p.Multiplier
+	if m < 1 {
+		m = 2
+	}
+	d := float64(p.Delay)
+	for i := 1; i < attempts; i++ {
+		d *= m
+		if p.MaxDelay > 0 && d >= float64(p.MaxDelay) {
+			d = float64(p.MaxDelay)
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d += d * j * (rand.Float64()*2 - 1)
+	}
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	return time.Duration(d)
+}
+
+// WithRetry 设置任务的重试策略，需要在任务开始前调用
+// 回调方法只在最后一次执行结束后调用一次，执行次数通过 Task.Attempts 获取
+func (t *Task) WithRetry(p *RetryPolicy) *Task {
+	t.retry = p
+	return t
+}
+
+// Attempts 任务已经执行的次数
+func (t *Task) Attempts() int {
+	return int(atomic.LoadInt32(&t.attempts))
+}
+
+// tryAgain 任务执行失败后判断是否重试，需要重试时等待一段时间后重新发送任务
+func (t *Task) tryAgain(err error) bool {
+	if t.retry == nil || t.dispatch == nil {
+		return false
+	}
+	attempts := t.Attempts()
+	if !t.retry.retryable(attempts, err) {
+		return false
+	}
+	if !atomic.CompareAndSwapInt32(&t.status, StatusRunning, StatusPending) {
+		return false
+	}
+	time.AfterFunc(t.retry.delay(attempts), t.send)
+	return true
+}
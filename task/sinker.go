@@ -0,0 +1,18 @@
+package task
+
+import "time"
+
+type sink struct {
+}
+
+func (s *sink) OnStart() {
+}
+
+func (s *sink) OnTick() {
+	if defaultMaster != nil {
+		defaultMaster.balance(time.Now())
+	}
+}
+
+func (s *sink) OnStop() {
+}
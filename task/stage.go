@@ -0,0 +1,122 @@
+package task
+
+import (
+	"context"
+
+	"github.com/skeletongo/core/basic"
+)
+
+// ThenFunc 任务执行成功后的后续处理方法
+// o 方法执行的节点
+// ret 上一步的返回值
+// 返回值传递给下一步
+type ThenFunc func(o *basic.Object, ret interface{}) (interface{}, error)
+
+// CatchFunc 任务执行失败后的处理方法
+// o 方法执行的节点
+// err 上一步的执行错误
+// 返回值传递给下一步，返回的错误为nil时后续的 Then 继续执行
+type CatchFunc func(o *basic.Object, err error) (interface{}, error)
+
+// FinallyFunc 无论成功失败都会执行的处理方法，不会修改执行结果
+type FinallyFunc func(o *basic.Object, ret interface{}, err error)
+
+// stage 任务的后续处理步骤
+type stage struct {
+	// executor 执行步骤的协程节点名称，为空时在回调方法执行节点 Task.O 中执行
+	executor string
+	then     ThenFunc
+	catch    CatchFunc
+	finally  FinallyFunc
+}
+
+// skip 根据上一步的执行结果判断是否跳过这一步
+func (s *stage) skip(err error) bool {
+	switch {
+	case s.then != nil:
+		return err != nil
+	case s.catch != nil:
+		return err == nil
+	default:
+		return false
+	}
+}
+
+func (s *stage) call(o *basic.Object, ret interface{}, err error) (interface{}, error) {
+	switch {
+	case s.then != nil:
+		return s.then(o, ret)
+	case s.catch != nil:
+		return s.catch(o, err)
+	default:
+		s.finally(o, ret, err)
+		return ret, err
+	}
+}
+
+// Then 添加执行成功后的后续处理方法，在回调方法执行节点 Task.O 中执行，需要在任务开始前调用
+// 上一步执行失败时跳过，直到遇到 Catch 或 Finally
+// 所有步骤执行结束后任务才结束，Future 和回调方法得到最后一步的执行结果
+func (t *Task) Then(f ThenFunc) *Task {
+	return t.ThenOn("", f)
+}
+
+// ThenOn 添加执行成功后的后续处理方法，在预创建的协程节点中执行，需要在任务开始前调用
+// executor 协程节点名称，和 StartByExecutor 的参数相同；为空时在回调方法执行节点 Task.O 中执行
+func (t *Task) ThenOn(executor string, f ThenFunc) *Task {
+	t.stages = append(t.stages, &stage{executor: executor, then: f})
+	return t
+}
+
+// Catch 添加执行失败后的处理方法，在回调方法执行节点 Task.O 中执行，需要在任务开始前调用
+// 上一步执行成功时跳过
+func (t *Task) Catch(f CatchFunc) *Task {
+	t.stages = append(t.stages, &stage{catch: f})
+	return t
+}
+
+// Finally 添加无论成功失败都会执行的处理方法，在回调方法执行节点 Task.O 中执行，需要在任务开始前调用
+func (t *Task) Finally(f FinallyFunc) *Task {
+	t.stages = append(t.stages, &stage{finally: f})
+	return t
+}
+
+// next 从第 i 步开始执行后续处理步骤，所有步骤执行结束后任务结束
+func (t *Task) next(i int, ret interface{}, err error) {
+	for ; i < len(t.stages); i++ {
+		if err == nil && t.ctx.Err() != nil {
+			// 任务被取消或超时，跳过后续的 Then
+			err = t.ctx.Err()
+		}
+		s := t.stages[i]
+		if s.skip(err) {
+			continue
+		}
+
+		n := i + 1
+		f := func(o *basic.Object) {
+			var r interface{}
+			var e error
+			t.withTrace(o, func() {
+				r, e = t.protect(func() (interface{}, error) {
+					return s.call(o, ret, err)
+				})
+			})
+			t.next(n, r, e)
+		}
+		if s.executor != "" {
+			sendStageToExecutor(t, s.executor, f)
+			return
+		}
+		if t.O == nil || t.O.IsClosed() {
+			t.complete(ret, context.Canceled)
+			return
+		}
+		t.O.Send(basic.CommandWrapper(func(o *basic.Object) error {
+			f(o)
+			return nil
+		}))
+		return
+	}
+	t.complete(ret, err)
+}
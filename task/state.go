@@ -0,0 +1,51 @@
+package task
+
+import (
+	"sort"
+	"time"
+)
+
+// ExecutorState 协程节点状态
+type ExecutorState struct {
+	Name       string    // 协程节点名称
+	Fixed      bool      // 是否是 StartByFixExecutor 创建的协程节点
+	QueueLen   uint64    // 待处理任务数量
+	DoneNum    uint64    // 已处理的消息数
+	LastActive time.Time // 最后一次处理任务的时间
+}
+
+// State 任务模块状态
+type State struct {
+	GoRunning int              // Start 正在运行的协程数量
+	GoPending int              // Start 排队等待的任务数量
+	Executors []*ExecutorState // 所有协程节点，按名称排序
+}
+
+// GetState 获取任务模块状态
+func GetState() *State {
+	ret := new(State)
+	ret.GoRunning, ret.GoPending = defaultGoPool.state()
+	if defaultMaster == nil {
+		return ret
+	}
+
+	m := defaultMaster
+	m.RLock()
+	for name, w := range m.workers {
+		st := w.State()
+		_, fixed := m.fixed[name]
+		ret.Executors = append(ret.Executors, &ExecutorState{
+			Name:       name,
+			Fixed:      fixed,
+			QueueLen:   st.QueueLen,
+			DoneNum:    st.DoneNum,
+			LastActive: w.lastActive,
+		})
+	}
+	m.RUnlock()
+
+	sort.Slice(ret.Executors, func(i, j int) bool {
+		return ret.Executors[i].Name < ret.Executors[j].Name
+	})
+	return ret
+}
@@ -1,11 +1,19 @@
 // 对多线程的支持
 // 主要有以下几个方法
 // Start：创建一个协程去执行，执行结束后协程关闭
-// StartByExecutor：在预创建的协程节点中执行
-// StartByFixExecutor：创建一个协程去执行，协程一旦创建就不会关闭
+// StartByExecutor：在预创建的协程节点中执行，协程节点数量根据负载自动调整
+// StartByFixExecutor：创建一个协程去执行，协程空闲超时后关闭
 package task
 
-import "github.com/skeletongo/core/basic"
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/log"
+)
 
 // defaultObject 回调方法默认执行节点
 var defaultObject *basic.Object
@@ -28,9 +36,30 @@ func (cw CallableWrapper) Call(o *basic.Object) (ret interface{}) {
 	return cw(o)
 }
 
+// ErrCallable 可以返回错误的 Callable
+// 任务执行时优先调用 CallErr 方法，返回的错误可以通过 Task.Err 和 Future 获取
+type ErrCallable interface {
+	Callable
+	// CallErr 需要在另外的协程中执行的方法
+	// o 协程节点，就是这个方法执行的节点
+	CallErr(o *basic.Object) (ret interface{}, err error)
+}
+
+type ErrCallableWrapper func(o *basic.Object) (ret interface{}, err error)
+
+func (cw ErrCallableWrapper) Call(o *basic.Object) (ret interface{}) {
+	ret, _ = cw(o)
+	return
+}
+
+func (cw ErrCallableWrapper) CallErr(o *basic.Object) (ret interface{}, err error) {
+	return cw(o)
+}
+
 type CompleteNotify interface {
 	// Done 回调方法
 	// ret Callable 方法的返回值
+	// t 任务，执行错误通过 t.Err() 获取
 	Done(ret interface{}, t *Task)
 }
 
@@ -47,13 +76,100 @@ type Task struct {
 	c    Callable       // 需要并发执行的方法
 	cb   CompleteNotify // 回调方法
 	ret  interface{}    // Callable 方法执行返回值
+	err  error          // Callable 方法执行错误
+	fut  *Future        // 执行结果
+
+	ctx      context.Context    // 任务的 context
+	cancel   context.CancelFunc // 取消任务
+	watch    bool               // 是否需要监听 ctx 的结束信号
+	status   int32              // 任务状态
+	finished int32              // 任务是否已经结束
+
+	retry    *RetryPolicy // 重试策略
+	attempts int32        // 已经执行的次数
+	dispatch func()       // 发送任务到执行节点，重试时使用
+	executor string       // StartByExecutor 的任务名称，执行期间固定在同一个协程节点
+
+	stages []*stage // 后续处理步骤
+	limit  *limiter // 当前执行占用的限流器
+
+	durable  *durable // 持久化任务信息
+	replayed bool     // 是否是重启后重新执行的任务
+
+	priority  int           // 优先级
+	notBefore time.Time     // 最早开始执行的时间
+	delay     time.Duration // 延迟执行的时长
+
+	traceID  string    // 追踪ID
+	queuedAt time.Time // 本次发送到执行节点的时间
 }
 
 func (t *Task) run(o *basic.Object) {
-	if t.c == nil {
+	defer t.releaseLimit()
+	if !t.begin() {
+		return
+	}
+	start := time.Now()
+	t.onBegin(o, start)
+	var ret interface{}
+	var err error
+	t.withTrace(o, func() {
+		ret, err = t.call(o)
+	})
+	t.onEnd(o, start, err)
+	if err == nil && t.ctx.Err() != nil {
+		// 执行期间任务被取消或超时
+		err = t.ctx.Err()
+	}
+	if t.tryAgain(err) {
+		return
+	}
+	t.next(0, ret, err)
+}
+
+// call 执行 Callable 方法
+func (t *Task) call(o *basic.Object) (ret interface{}, err error) {
+	return t.protect(func() (interface{}, error) {
+		if t.c == nil {
+			return nil, nil
+		}
+		if c, ok := t.c.(ErrCallable); ok {
+			return c.CallErr(o)
+		}
+		return t.c.Call(o), nil
+	})
+}
+
+// protect 执行方法 f，发生 panic 时返回 *PanicError
+func (t *Task) protect(f func() (interface{}, error)) (ret interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var buf [4096]byte
+			n := runtime.Stack(buf[:], false)
+			err = &PanicError{Name: t.Name, Value: r, Stack: string(buf[:n])}
+			_ = log.Errorf("Task [%s] panic: %v\nstack--->%s", t.Name, r, buf[:n])
+		}
+	}()
+	return f()
+}
+
+// complete 任务执行结束
+func (t *Task) complete(ret interface{}, err error) {
+	if !atomic.CompareAndSwapInt32(&t.finished, 0, 1) {
 		return
 	}
-	t.ret = t.c.Call(o)
+	t.ret = ret
+	t.err = err
+	atomic.StoreInt32(&t.status, statusOf(err))
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		t.onCanceled()
+	}
+	if t.durable != nil && defaultJournal != nil {
+		defaultJournal.ack(t)
+	}
+	t.fut.complete(ret, err)
+	// 释放 context 资源
+	t.cancel()
 	if t.cb == nil {
 		return
 	}
@@ -61,6 +177,16 @@ func (t *Task) run(o *basic.Object) {
 	sendCallback(t.O, t)
 }
 
+// Err 任务执行错误
+func (t *Task) Err() error {
+	return t.err
+}
+
+// Future 获取任务执行结果
+func (t *Task) Future() *Future {
+	return t.fut
+}
+
 // New 创建任务
 // o 回调方法执行的节点
 // c 需要并发执行的方法
@@ -78,23 +204,67 @@ func New(o *basic.Object, c Callable, cb CompleteNotify, name ...string) *Task {
 	if o == nil {
 		ret.O = defaultObject
 	}
+	if ret.O != nil {
+		ret.traceID = ret.O.TraceID()
+	}
+	ret.fut = newFuture(ret)
+	ret.ctx, ret.cancel = context.WithCancel(context.Background())
 	return ret
 }
 
 // Start 创建一个协程去执行，执行结束后协程关闭
-func (t *Task) Start() {
-	go t.run(nil)
+// 同时运行的协程数量达到配置的上限时，任务排队等待空闲的协程
+// 返回任务执行结果
+func (t *Task) Start() *Future {
+	t.onSubmit()
+	t.bindTrace()
+	if !t.persist(modeGo, "") {
+		return t.fut
+	}
+	t.startWatch()
+	t.dispatch = func() {
+		defaultGoPool.run(t)
+	}
+	t.schedule()
+	return t.fut
 }
 
 // StartByExecutor 在预创建的协程节点中执行
-// name 任务名称，名称相同的任务会在同一个协程中串行执行
-func (t *Task) StartByExecutor(name string) {
-	sendToExecutor(t, name)
+// name 任务名称，名称相同的任务会在同一个协程中串行执行，可以通过 SetLimit 限流
+// 返回任务执行结果
+func (t *Task) StartByExecutor(name string) *Future {
+	t.onSubmit()
+	t.bindTrace()
+	if !t.persist(modeExecutor, name) {
+		return t.fut
+	}
+	t.startWatch()
+	t.dispatch = func() {
+		limitDispatch(t, name, func() {
+			sendToExecutor(t, name)
+		})
+	}
+	t.schedule()
+	return t.fut
 }
 
-// StartByFixExecutor 创建一个协程去执行，协程一旦创建就不会关闭
+// StartByFixExecutor 创建一个协程去执行，协程空闲超过配置的时长后关闭
 // 如果已经有任务名称相同的协程了(已经使用相同的name调用过此方法)，就不会再创建新协程
-// name 任务名称，名称相同的任务会在同一个协程中串行执行
-func (t *Task) StartByFixExecutor(name string) {
-	sendToFixExecutor(t, name)
+// 协程数量达到上限并且没有空闲协程时，任务执行失败，返回 ErrTooManyFixWorker
+// name 任务名称，名称相同的任务会在同一个协程中串行执行，可以通过 SetLimit 限流
+// 返回任务执行结果
+func (t *Task) StartByFixExecutor(name string) *Future {
+	t.onSubmit()
+	t.bindTrace()
+	if !t.persist(modeFix, name) {
+		return t.fut
+	}
+	t.startWatch()
+	t.dispatch = func() {
+		limitDispatch(t, name, func() {
+			sendToFixExecutor(t, name)
+		})
+	}
+	t.schedule()
+	return t.fut
 }
@@ -1,9 +1,12 @@
 package task_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/skeletongo/core/basic"
 	"github.com/skeletongo/core/task"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -92,3 +95,420 @@ func TestTask_StartByExecutor(t *testing.T) {
 func ExampleTask_StartByFixExecutor() {
 
 }
+
+func TestFuture_Wait(t *testing.T) {
+	f := task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		time.Sleep(time.Millisecond * 50)
+		return 1
+	}), nil).Start()
+
+	if _, err := f.Result(); err != task.ErrNotDone {
+		t.Error("1", err)
+	}
+	if _, err := f.Wait(time.Millisecond); err != task.ErrTimeout {
+		t.Error("2", err)
+	}
+	ret, err := f.Wait(time.Second)
+	if err != nil || ret != 1 {
+		t.Error("3", ret, err)
+	}
+}
+
+func TestTask_Panic(t *testing.T) {
+	ch := make(chan error, 1)
+	f := task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		panic("test")
+	}), task.CompleteNotifyWrapper(func(ret interface{}, t *task.Task) {
+		ch <- t.Err()
+	}), "panic").StartByExecutor("panic")
+
+	_, err := f.Wait(time.Second)
+	if e, ok := err.(*task.PanicError); !ok || e.Value != "test" || e.Name != "panic" {
+		t.Error("1", err)
+	}
+	if err = <-ch; err == nil {
+		t.Error("2")
+	}
+}
+
+func TestAwait(t *testing.T) {
+	ch := make(chan []interface{}, 1)
+	var fs []*task.Future
+	for i := 0; i < 3; i++ {
+		n := i
+		fs = append(fs, task.New(task.Obj, task.ErrCallableWrapper(func(o *basic.Object) (interface{}, error) {
+			time.Sleep(time.Millisecond * time.Duration(10*(3-n)))
+			if n == 1 {
+				return nil, errors.New("err")
+			}
+			return n, nil
+		}), nil).Start())
+	}
+	task.Await(task.Obj, func(fs []*task.Future) {
+		var res []interface{}
+		for _, f := range fs {
+			ret, err := f.Result()
+			if err != nil {
+				res = append(res, err.Error())
+			} else {
+				res = append(res, ret)
+			}
+		}
+		ch <- res
+	}, fs...)
+
+	select {
+	case res := <-ch:
+		if fmt.Sprint(res) != "[0 err 2]" {
+			t.Error(res)
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout")
+	}
+}
+
+func TestAwait_NilObject(t *testing.T) {
+	ch := make(chan int, 1)
+	f := task.New(nil, task.CallableWrapper(func(o *basic.Object) interface{} {
+		return 1
+	}), nil).Start()
+	// 没有默认节点时直接执行回调方法
+	task.Await(nil, func(fs []*task.Future) {
+		ret, _ := fs[0].Result()
+		ch <- ret.(int)
+	}, f)
+	select {
+	case n := <-ch:
+		if n != 1 {
+			t.Error(n)
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout")
+	}
+}
+
+func TestTask_Cancel(t *testing.T) {
+	ch := make(chan int, 1)
+	block := make(chan struct{})
+	task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		<-block
+		return nil
+	}), nil).StartByFixExecutor("cancel")
+
+	// 排队中的任务取消后立即结束，不再执行
+	var called bool
+	tk := task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		called = true
+		return nil
+	}), task.CompleteNotifyWrapper(func(ret interface{}, t *task.Task) {
+		ch <- t.Status()
+	}))
+	f := tk.StartByFixExecutor("cancel")
+	tk.Cancel()
+	if _, err := f.Wait(time.Second); err != context.Canceled {
+		t.Error("1", err)
+	}
+	if status := <-ch; status != task.StatusCanceled {
+		t.Error("2", status)
+	}
+	close(block)
+
+	f = task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		return nil
+	}), nil).StartByFixExecutor("cancel")
+	if _, err := f.Wait(time.Second); err != nil || called {
+		t.Error("3", err, called)
+	}
+}
+
+func TestTask_WithTimeout(t *testing.T) {
+	var tk *task.Task
+	tk = task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		select {
+		case <-tk.Context().Done():
+		case <-time.After(time.Second):
+		}
+		return nil
+	}), nil).WithTimeout(time.Millisecond * 20)
+	_, err := tk.Start().Wait(time.Second)
+	if err != context.DeadlineExceeded || !tk.IsTimeout() {
+		t.Error(err, tk.Status())
+	}
+}
+
+func TestTask_WithRetry(t *testing.T) {
+	var n int32
+	errRetry := errors.New("retry")
+	ch := make(chan int, 1)
+	f := task.New(task.Obj, task.ErrCallableWrapper(func(o *basic.Object) (interface{}, error) {
+		if atomic.AddInt32(&n, 1) < 3 {
+			return nil, errRetry
+		}
+		return "ok", nil
+	}), task.CompleteNotifyWrapper(func(ret interface{}, t *task.Task) {
+		ch <- t.Attempts()
+	})).WithRetry(&task.RetryPolicy{
+		MaxAttempts: 5,
+		Delay:       time.Millisecond,
+		Jitter:      0.5,
+	}).StartByExecutor("retry")
+
+	ret, err := f.Wait(time.Second)
+	if ret != "ok" || err != nil {
+		t.Error("1", ret, err)
+	}
+	if attempts := <-ch; attempts != 3 {
+		t.Error("2", attempts)
+	}
+
+	// 不可重试的错误
+	f = task.New(task.Obj, task.ErrCallableWrapper(func(o *basic.Object) (interface{}, error) {
+		return nil, errRetry
+	}), nil).WithRetry(&task.RetryPolicy{
+		MaxAttempts: 5,
+		Retryable: func(err error) bool {
+			return err != errRetry
+		},
+	}).Start()
+	if _, err = f.Wait(time.Second); err != errRetry || f.Task().Attempts() != 1 {
+		t.Error("3", err, f.Task().Attempts())
+	}
+}
+
+func TestGetState(t *testing.T) {
+	f := task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		return nil
+	}), nil).StartByFixExecutor("state")
+	f.Wait(time.Second)
+
+	var fixed bool
+	for _, v := range task.GetState().Executors {
+		if v.Name == "state" {
+			fixed = v.Fixed
+		}
+	}
+	if !fixed {
+		t.Error("fix executor not found")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	ch := make(chan *task.Group, 1)
+	g := task.NewGroup(task.Obj, task.WaitAll, task.GroupNotifyWrapper(func(g *task.Group) {
+		ch <- g
+	}), "all")
+	for i := 0; i < 3; i++ {
+		n := i
+		g.Add(task.CallableWrapper(func(o *basic.Object) interface{} {
+			time.Sleep(time.Millisecond * time.Duration(10*(3-n)))
+			return n
+		}))
+	}
+	g.Start()
+	if g = <-ch; fmt.Sprint(g.Results()) != "[0 1 2]" || g.Err() != nil {
+		t.Error("1", g.Results(), g.Err())
+	}
+
+	// 第一个错误结束任务组，取消其它任务
+	errGroup := errors.New("group")
+	g = task.NewGroup(task.Obj, task.WaitFirstError, task.GroupNotifyWrapper(func(g *task.Group) {
+		ch <- g
+	}), "first error")
+	g.Add(task.ErrCallableWrapper(func(o *basic.Object) (interface{}, error) {
+		time.Sleep(time.Millisecond * 20)
+		return nil, errGroup
+	}))
+	var tk *task.Task
+	tk = g.Add(task.CallableWrapper(func(o *basic.Object) interface{} {
+		<-tk.Context().Done()
+		return 1
+	}))
+	g.Start()
+	g = <-ch
+	if g.Err() != errGroup {
+		t.Error("2", g.Err())
+	}
+	if _, err := g.Tasks()[1].Future().Wait(time.Second); err != context.Canceled {
+		t.Error("3", err)
+	}
+
+	// 没有回调方法执行节点时，在任务的协程中执行回调方法
+	g = task.NewGroup(nil, task.WaitAny, task.GroupNotifyWrapper(func(g *task.Group) {
+		ch <- g
+	}))
+	g.Add(task.CallableWrapper(func(o *basic.Object) interface{} {
+		return 1
+	}))
+	g.Start()
+	if g = <-ch; g.Winner() != 0 {
+		t.Error("4", g.Winner())
+	}
+
+	// 零值的任务组可以直接使用
+	g = new(task.Group)
+	g.Add(task.CallableWrapper(func(o *basic.Object) interface{} {
+		return 1
+	}))
+	g.Start()
+	if ret, err := g.Tasks()[0].Future().Wait(time.Second); err != nil || ret != 1 {
+		t.Error("5", ret, err)
+	}
+}
+
+func TestTask_Then(t *testing.T) {
+	errThen := errors.New("then")
+	var finally bool
+	f := task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		return 1
+	}), nil).ThenOn("then", func(o *basic.Object, ret interface{}) (interface{}, error) {
+		return ret.(int) + 1, nil
+	}).Then(func(o *basic.Object, ret interface{}) (interface{}, error) {
+		if o != task.Obj {
+			t.Error("then object")
+		}
+		return ret, errThen
+	}).Then(func(o *basic.Object, ret interface{}) (interface{}, error) {
+		t.Error("should skip")
+		return ret, nil
+	}).Catch(func(o *basic.Object, err error) (interface{}, error) {
+		if err != errThen {
+			t.Error("catch", err)
+		}
+		return 10, nil
+	}).Finally(func(o *basic.Object, ret interface{}, err error) {
+		finally = true
+	}).Start()
+
+	ret, err := f.Wait(time.Second)
+	if ret != 10 || err != nil || !finally {
+		t.Error(ret, err, finally)
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	task.SetLimit("limit", &task.Limit{Rate: 50, Burst: 1, MaxInFlight: 1})
+	defer task.SetLimit("limit", nil)
+
+	start := time.Now()
+	var fs []*task.Future
+	var order []int
+	for i := 0; i < 5; i++ {
+		n := i
+		fs = append(fs, task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+			order = append(order, n)
+			return nil
+		}), nil).StartByFixExecutor("limit"))
+	}
+	for _, f := range fs {
+		f.Wait(time.Second)
+	}
+	// 第一个任务直接执行，之后每20ms执行一个
+	if d := time.Since(start); d < time.Millisecond*70 {
+		t.Error("1", d)
+	}
+	if fmt.Sprint(order) != "[0 1 2 3 4]" {
+		t.Error("2", order)
+	}
+	st := task.GetLimitStates()
+	if len(st) != 1 || st[0].Passed != 5 || st[0].Throttled == 0 || st[0].InFlight != 0 {
+		t.Error("3", *st[0])
+	}
+}
+
+func TestTask_WithPriority(t *testing.T) {
+	block := make(chan struct{})
+	task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		<-block
+		return nil
+	}), nil).StartByFixExecutor("priority")
+
+	var order []int
+	var fs []*task.Future
+	for i, p := range []int{task.PriorityLow, task.PriorityNormal, task.PriorityUrgent, task.PriorityHigh, task.PriorityUrgent} {
+		n := i
+		fs = append(fs, task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+			order = append(order, n)
+			return nil
+		}), nil).WithPriority(p).StartByFixExecutor("priority"))
+	}
+	// 延时任务不阻塞协程节点
+	delayed := task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		return time.Now()
+	}), nil).Delay(time.Millisecond * 50)
+	start := time.Now()
+	df := delayed.StartByFixExecutor("priority")
+
+	time.Sleep(time.Millisecond * 10)
+	close(block)
+	for _, f := range fs {
+		f.Wait(time.Second)
+	}
+	if fmt.Sprint(order) != "[2 4 3 1 0]" {
+		t.Error("1", order)
+	}
+	if time.Since(start) > time.Millisecond*40 {
+		t.Error("2 blocked by delayed task")
+	}
+	ret, err := df.Wait(time.Second)
+	if err != nil || ret.(time.Time).Sub(start) < time.Millisecond*50 {
+		t.Error("3", ret, err)
+	}
+}
+
+func TestGetMetrics(t *testing.T) {
+	task.Obj.SetTraceID("trace-1")
+	var trace string
+	f1 := task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		trace = o.TraceID()
+		return nil
+	}), nil, "metrics").StartByExecutor("metrics")
+	task.Obj.SetTraceID("")
+	f2 := task.New(task.Obj, task.CallableWrapper(func(o *basic.Object) interface{} {
+		panic("metrics")
+	}), nil, "metrics").StartByExecutor("metrics")
+	f1.Wait(time.Second)
+	f2.Wait(time.Second)
+
+	if trace != "trace-1" || f1.Task().TraceID() != "trace-1" {
+		t.Error("1", trace)
+	}
+	m := task.GetMetrics().Names["metrics"]
+	if m == nil || m.Submitted != 2 || m.Succeeded != 1 || m.Panicked != 1 || m.Running != 0 {
+		t.Fatal("2", m)
+	}
+	if m.Run.Count != 2 || m.Queued.Count != 2 {
+		t.Error("3", m.Run, m.Queued)
+	}
+
+	// Start 执行时 o 为nil，通过任务的 context 获取追踪ID，子任务继承追踪ID
+	var tk *task.Task
+	ch := make(chan string, 1)
+	tk = task.New(nil, task.CallableWrapper(func(o *basic.Object) interface{} {
+		sub := task.New(nil, task.CallableWrapper(func(o *basic.Object) interface{} {
+			return nil
+		}), nil).WithContext(tk.Context())
+		sub.Start().Wait(time.Second)
+		ch <- task.TraceIDFromContext(tk.Context()) + "," + sub.TraceID()
+		return nil
+	}), nil).WithTraceID("trace-2")
+	tk.Start()
+	if v := <-ch; v != "trace-2,trace-2" {
+		t.Error("4", v)
+	}
+
+	// 任务名称的统计数量超出上限时删除最久没有更新的统计
+	max := task.MaxMetrics
+	task.MaxMetrics = 2
+	defer func() {
+		task.MaxMetrics = max
+	}()
+	for _, name := range []string{"m1", "m2", "m3"} {
+		task.New(nil, task.CallableWrapper(func(o *basic.Object) interface{} {
+			return nil
+		}), nil, name).Start().Wait(time.Second)
+	}
+	names := task.GetMetrics().Names
+	if _, ok := names["m1"]; ok || len(names) != 2 {
+		t.Error("5", len(names))
+	}
+}
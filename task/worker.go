@@ -1,55 +1,173 @@
 package task
 
 import (
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/log"
 
 	"github.com/stathat/consistent"
 )
 
 var defaultMaster *master
 
+var ErrTooManyFixWorker = errors.New("Too many fix worker ")
+
+// replicas 一致性哈希中每个协程节点的虚拟节点数量，越大任务分布越均匀
+const replicas = 100
+
 // worker 协程节点
 type worker struct {
 	*basic.Object
+	// q 等待执行的任务，按优先级排序
+	q *taskQueue
+	// lastDone 上次检查时已处理的消息数
+	lastDone uint64
+	// lastActive 最后一次处理消息的时间
+	lastActive time.Time
+	// pins 固定在这个节点中执行的任务名称数量，大于0时不回收
+	pins int
+}
+
+// busy 是否有正在执行或等待执行的消息
+// QueueLen 不包含正在执行的消息，所以使用收到的消息数和已处理的消息数判定
+func (w *worker) busy() bool {
+	st := w.State()
+	return st.EnqueueNum != st.DoneNum
+}
+
+// active 更新协程节点的活跃时间
+func (w *worker) active(now time.Time) {
+	st := w.State()
+	if st.EnqueueNum != st.DoneNum || st.DoneNum != w.lastDone {
+		w.lastActive = now
+	}
+	w.lastDone = st.DoneNum
+}
+
+// touch 更新协程节点的活跃时间
+func (m *master) touch(w *worker, now time.Time) {
+	m.Lock()
+	w.lastActive = now
+	m.Unlock()
+}
+
+// isIdle 协程节点是否空闲了 d 时长，有任务正在执行或等待执行时不是空闲的
+func (w *worker) isIdle(now time.Time, d time.Duration) bool {
+	return d > 0 && w.pins == 0 && !w.busy() && now.Sub(w.lastActive) >= d
 }
 
+// pin 任务名称固定使用的协程节点
+type pin struct {
+	w *worker
+	// n 正在执行或等待执行的任务数量
+	n int
+}
+
+// master 协程节点管理器，只在 Obj 节点中使用
+// 修改协程节点需要加锁，用来支持在其它协程中获取状态
 type master struct {
-	// 预创建协程的序号
+	sync.RWMutex
+	// 协程节点的序号
 	i int
 	c *consistent.Consistent
 	// 所有协程节点
 	workers map[string]*worker
+	// pool 预创建的协程节点，数量根据负载在 [min,max] 之间调整
+	pool map[string]*worker
+	min  int
+	max  int
+	// fixed StartByFixExecutor 创建的协程节点，空闲超时后回收
+	fixed map[string]*worker
+	// pins 有任务正在执行或等待执行的 StartByExecutor 任务名称
+	// 这些任务结束前，扩容或回收不会让同名任务改为在其它节点中执行，保证同名任务串行执行
+	pins map[string]*pin
 }
 
-func newMaster(n int) *master {
+func newMaster(cfg *WorkerConfig) *master {
 	m := &master{
 		c:       consistent.New(),
 		workers: make(map[string]*worker),
+		pool:    make(map[string]*worker),
+		fixed:   make(map[string]*worker),
+		pins:    make(map[string]*pin),
+		min:     cfg.MinWorker,
+		max:     cfg.MaxWorker,
 	}
+	m.c.NumberOfReplicas = replicas
 
-	for i := 0; i < n; i++ {
+	for i := 0; i < cfg.WorkerCnt; i++ {
 		m.addWorker()
 	}
 	return m
 }
 
 func (m *master) addWorkerByName(name string) *worker {
-	w := new(worker)
+	w := &worker{q: newTaskQueue()}
 	w.Object = basic.NewObject(m.i, name, Config.Worker.Options, nil)
 	w.Object.Run()
 	w.Data = w
+	w.lastActive = time.Now()
 	Obj.AddChild(w.Object)
+	m.Lock()
 	m.workers[w.Name] = w
+	m.Unlock()
 	m.i++
 	return w
 }
 
+// addWorker 添加预创建的协程节点
+// 一致性哈希保证只有一小部分任务名称会改为在新节点中执行
 func (m *master) addWorker() *worker {
 	name := fmt.Sprintf("worker_%d", m.i)
+	w := m.addWorkerByName(name)
+	m.Lock()
+	m.pool[name] = w
+	m.Unlock()
 	m.c.Add(name)
-	return m.addWorkerByName(name)
+	return w
+}
+
+// removeWorker 回收协程节点
+// 节点会在处理完已经收到的任务后关闭
+func (m *master) removeWorker(w *worker) {
+	if _, ok := m.pool[w.Name]; ok {
+		m.c.Remove(w.Name)
+	}
+	m.Lock()
+	delete(m.pool, w.Name)
+	delete(m.fixed, w.Name)
+	delete(m.workers, w.Name)
+	m.Unlock()
+	w.Close()
+	removeMetric(w.Name)
+}
+
+// addFixWorker 添加 StartByFixExecutor 使用的协程节点
+// 数量达到上限时回收最久没有使用的空闲节点，有任务正在执行或等待执行的节点不是空闲的，没有空闲节点时返回nil
+func (m *master) addFixWorker(name string) *worker {
+	if max := Config.Worker.MaxFixWorker; max > 0 && len(m.fixed) >= max {
+		var idle *worker
+		for _, w := range m.fixed {
+			if !w.busy() && (idle == nil || w.lastActive.Before(idle.lastActive)) {
+				idle = w
+			}
+		}
+		if idle == nil {
+			return nil
+		}
+		m.removeWorker(idle)
+		log.Infof("task fix worker [%s] removed, too many fix workers", idle.Name)
+	}
+
+	w := m.addWorkerByName(name)
+	m.Lock()
+	m.fixed[name] = w
+	m.Unlock()
+	return w
 }
 
 func (m *master) getWorkerByName(name string) *worker {
@@ -66,3 +184,67 @@ func (m *master) getWorker(name string) *worker {
 	}
 	return m.getWorkerByName(workName)
 }
+
+// acquire 获取任务名称对应的协程节点，并固定到任务结束，需要和 release 成对调用
+// 任务名称已经固定时使用固定的节点，否则通过一致性哈希选择
+func (m *master) acquire(name string) *worker {
+	if p, ok := m.pins[name]; ok {
+		p.n++
+		return p.w
+	}
+	w := m.getWorker(name)
+	if w == nil {
+		return nil
+	}
+	m.pins[name] = &pin{w: w, n: 1}
+	w.pins++
+	return w
+}
+
+// release 任务结束，任务名称没有正在执行或等待执行的任务时取消固定
+func (m *master) release(name string) {
+	p, ok := m.pins[name]
+	if !ok {
+		return
+	}
+	p.n--
+	if p.n <= 0 {
+		delete(m.pins, name)
+		p.w.pins--
+	}
+}
+
+// balance 根据负载调整预创建的协程节点数量，每次最多增加或回收一个；回收空闲的 StartByFixExecutor 协程节点
+func (m *master) balance(now time.Time) {
+	m.Lock()
+	for _, w := range m.workers {
+		w.active(now)
+	}
+	m.Unlock()
+
+	for _, w := range m.fixed {
+		if w.isIdle(now, Config.Worker.FixIdleTimeout) {
+			m.removeWorker(w)
+			log.Infof("task fix worker [%s] removed, idle timeout", w.Name)
+		}
+	}
+
+	var queueLen uint64
+	var idle *worker
+	for _, w := range m.pool {
+		queueLen += w.State().QueueLen
+		if idle == nil && w.isIdle(now, Config.Worker.IdleTimeout) {
+			idle = w
+		}
+	}
+
+	n := len(m.pool)
+	switch {
+	case n < m.max && (n == 0 || queueLen/uint64(n) >= uint64(Config.Worker.GrowQueueLen)):
+		w := m.addWorker()
+		log.Infof("task worker [%s] added, queue: %d, workers: %d", w.Name, queueLen, n+1)
+	case n > m.min && idle != nil:
+		m.removeWorker(idle)
+		log.Infof("task worker [%s] removed, workers: %d", idle.Name, n-1)
+	}
+}
@@ -0,0 +1,151 @@
+package task
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+)
+
+// doInObj 在 Obj 节点中执行，等待执行结束
+func doInObj(f func()) {
+	ch := make(chan struct{})
+	Obj.Send(basic.CommandWrapper(func(o *basic.Object) error {
+		f()
+		close(ch)
+		return nil
+	}))
+	<-ch
+}
+
+func TestMaster_FixWorker(t *testing.T) {
+	doInObj(func() {
+		Config.Worker.MaxFixWorker = 2
+		Config.Worker.FixIdleTimeout = time.Millisecond * 20
+	})
+	defer doInObj(func() {
+		Config.Worker.MaxFixWorker = 0
+		Config.Worker.FixIdleTimeout = 0
+	})
+
+	for _, name := range []string{"fix_a", "fix_b", "fix_c"} {
+		New(Obj, CallableWrapper(func(o *basic.Object) interface{} {
+			return nil
+		}), nil).StartByFixExecutor(name).Wait(time.Second)
+	}
+
+	var n int
+	doInObj(func() {
+		for name := range defaultMaster.fixed {
+			if name == "fix_a" {
+				t.Error("fix_a should be removed")
+			}
+		}
+		n = len(defaultMaster.fixed)
+	})
+	if n > 2 {
+		t.Error("too many fix workers", n)
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	doInObj(func() {
+		defaultMaster.balance(time.Now())
+		n = len(defaultMaster.fixed)
+	})
+	if n != 0 {
+		t.Error("idle fix workers not removed", n)
+	}
+	// 回收的协程节点删除统计
+	if _, ok := GetMetrics().Executors["fix_b"]; ok {
+		t.Error("fix worker metric not removed")
+	}
+}
+
+func TestMaster_BusyFixWorker(t *testing.T) {
+	doInObj(func() {
+		Config.Worker.MaxFixWorker = 1
+		Config.Worker.FixIdleTimeout = time.Millisecond * 20
+	})
+	defer doInObj(func() {
+		Config.Worker.MaxFixWorker = 0
+		Config.Worker.FixIdleTimeout = 0
+	})
+
+	// 执行时间超过 FixIdleTimeout 的任务，执行期间协程节点不会被回收
+	started := make(chan struct{})
+	f := New(Obj, CallableWrapper(func(o *basic.Object) interface{} {
+		close(started)
+		time.Sleep(time.Millisecond * 150)
+		return nil
+	}), nil).StartByFixExecutor("fix_long")
+	<-started
+	time.Sleep(time.Millisecond * 100)
+	doInObj(func() {
+		if _, ok := defaultMaster.fixed["fix_long"]; !ok {
+			t.Error("busy fix worker removed")
+		}
+	})
+
+	// 数量达到上限时不回收正在执行任务的协程节点
+	doInObj(func() {
+		if w := defaultMaster.addFixWorker("fix_other"); w != nil {
+			t.Error("busy fix worker evicted")
+		}
+	})
+	if _, err := f.Wait(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMaster_BusyWorker(t *testing.T) {
+	var min int
+	doInObj(func() {
+		min = defaultMaster.min
+		defaultMaster.min = 0
+		Config.Worker.IdleTimeout = time.Millisecond * 20
+	})
+	defer doInObj(func() {
+		defaultMaster.min = min
+		Config.Worker.IdleTimeout = 0
+		for len(defaultMaster.pool) < Config.Worker.WorkerCnt {
+			defaultMaster.addWorker()
+		}
+	})
+
+	// 执行时间超过 IdleTimeout 的任务，执行期间协程节点不会被回收
+	var running, maxRunning int32
+	names := make(chan string, 2)
+	call := CallableWrapper(func(o *basic.Object) interface{} {
+		if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		names <- o.Name
+		time.Sleep(time.Millisecond * 150)
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+	f1 := New(Obj, call, nil).StartByExecutor("long")
+	name := <-names
+	time.Sleep(time.Millisecond * 100)
+	doInObj(func() {
+		if _, ok := defaultMaster.pool[name]; !ok {
+			t.Error("busy worker removed", name)
+		}
+		if len(defaultMaster.pool) != 1 {
+			t.Error("idle workers not removed", len(defaultMaster.pool))
+		}
+	})
+
+	// 同名任务在同一个协程节点中串行执行
+	f2 := New(Obj, call, nil).StartByExecutor("long")
+	if _, err := f1.Wait(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f2.Wait(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if name2 := <-names; name2 != name || atomic.LoadInt32(&maxRunning) != 1 {
+		t.Error("tasks with the same name should run serially on one worker", name, name2, maxRunning)
+	}
+}
@@ -0,0 +1,36 @@
+package timer
+
+import "time"
+
+// Stopper 取消延时执行
+type Stopper interface {
+	// Stop 取消执行，已经执行或已经取消时返回false
+	Stop() bool
+}
+
+// Backend 定时器的底层实现
+type Backend interface {
+	// AfterFunc 延时 d 后在其它协程中执行 f，返回值用来取消执行
+	AfterFunc(d time.Duration, f func()) Stopper
+}
+
+// runtimeBackend 每个定时器使用一个 time.AfterFunc
+type runtimeBackend struct{}
+
+func (runtimeBackend) AfterFunc(d time.Duration, f func()) Stopper {
+	return time.AfterFunc(d, f)
+}
+
+// Runtime 默认的定时器实现，每个定时器使用一个 time.AfterFunc
+var Runtime Backend = runtimeBackend{}
+
+var backend = Runtime
+
+// SetBackend 设置定时器的底层实现，需要在创建定时器前调用，为nil时使用 Runtime
+// 定时器数量很多时可以使用 NewWheel 创建的时间轮
+func SetBackend(b Backend) {
+	if b == nil {
+		b = Runtime
+	}
+	backend = b
+}
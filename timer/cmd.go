@@ -1,6 +1,8 @@
 package timer
 
 import (
+	"time"
+
 	"github.com/skeletongo/core/basic"
 	"github.com/skeletongo/core/log"
 )
@@ -21,3 +23,23 @@ func SendTimer(o *basic.Object, t *Timer) {
 		return nil
 	}))
 }
+
+// sendEntry 发送定时器到执行节点执行
+// seq 本次执行对应的计时序号
+// due 计划执行时间，用来统计延迟执行，为零值时不统计
+func sendEntry(o *basic.Object, e *entry, seq uint64, due time.Time) {
+	if o == nil {
+		log.Warnf("Timer error: no object")
+		e.cancelRun()
+		return
+	}
+	o.Send(basic.CommandWrapper(func(o *basic.Object) error {
+		if e.isCanceled() || o.IsClosed() {
+			e.cancelRun()
+			return nil
+		}
+		onLate(due)
+		e.run(seq)
+		return nil
+	}))
+}
@@ -0,0 +1,187 @@
+package timer
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/log"
+)
+
+// CronOptions cron 定时方法的选项
+type CronOptions struct {
+	// Name 名称，用来查询执行记录和输出日志，可以为空
+	Name string
+	// Jitter 每次执行随机推迟 [0,Jitter) 的时长，避免大量定时方法同时执行，需要小于执行间隔
+	Jitter time.Duration
+	// SkipIfRunning 上一次还没有执行结束时跳过本次执行，包括还在执行节点的消息队列中等待
+	SkipIfRunning bool
+	// MaxRuntime 执行超过这个时长时输出警告日志，为0时不检查
+	MaxRuntime time.Duration
+	// History 保留最近执行记录的数量，为0时不保留
+	History int
+}
+
+// CronRun cron 定时方法的一次执行记录
+type CronRun struct {
+	Start    time.Time     // 开始执行的时间，跳过时为计划执行的时间
+	Duration time.Duration // 执行时长
+	Panic    interface{}   // 执行时发生的 panic，没有时为nil
+	Skipped  bool          // 上一次还没有执行结束，跳过本次执行
+}
+
+// history 最近的执行记录
+type history struct {
+	sync.Mutex
+	runs []CronRun
+	next int
+	size int
+}
+
+func newHistory(size int) *history {
+	return &history{size: size}
+}
+
+func (h *history) add(r CronRun) {
+	h.Lock()
+	defer h.Unlock()
+	if len(h.runs) < h.size {
+		h.runs = append(h.runs, r)
+		return
+	}
+	h.runs[h.next] = r
+	h.next = (h.next + 1) % h.size
+}
+
+// get 按时间顺序返回执行记录
+func (h *history) get() []CronRun {
+	h.Lock()
+	defer h.Unlock()
+	ret := make([]CronRun, 0, len(h.runs))
+	ret = append(ret, h.runs[h.next:]...)
+	ret = append(ret, h.runs[:h.next]...)
+	return ret
+}
+
+// histories 执行记录; key:Handle,value:*history
+var histories = new(sync.Map)
+
+// namedHistories 执行记录; key:名称,value:*history
+var namedHistories = new(sync.Map)
+
+// cronOption 定时器的 cron 选项
+type cronOption struct {
+	CronOptions
+	running int32 // 正在执行或等待执行的次数
+	hist    *history
+}
+
+func newCronOption(h Handle, opt *CronOptions) *cronOption {
+	ret := &cronOption{CronOptions: *opt}
+	if opt.History > 0 {
+		ret.hist = newHistory(opt.History)
+		if opt.Name != "" {
+			// 相同名称的定时方法共用执行记录
+			v, _ := namedHistories.LoadOrStore(opt.Name, ret.hist)
+			ret.hist = v.(*history)
+		}
+		histories.Store(h, ret.hist)
+	}
+	return ret
+}
+
+// jitter 随机推迟的时长
+func (c *cronOption) jitter() time.Duration {
+	if c.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(c.Jitter)))
+}
+
+// skip 计时结束时判断是否跳过本次执行
+func (c *cronOption) skip(now time.Time) bool {
+	if !c.SkipIfRunning || atomic.LoadInt32(&c.running) == 0 {
+		atomic.AddInt32(&c.running, 1)
+		return false
+	}
+	if c.hist != nil {
+		c.hist.add(CronRun{Start: now, Skipped: true})
+	}
+	_ = log.Warnf("Timer cron [%s] skipped, previous run not finished", c.Name)
+	return true
+}
+
+// run 在执行节点中执行，记录执行时长和 panic，panic 不会传递到执行节点
+func (c *cronOption) run(e *entry) {
+	start := time.Now()
+	var warn *time.Timer
+	if c.MaxRuntime > 0 {
+		warn = time.AfterFunc(c.MaxRuntime, func() {
+			_ = log.Warnf("Timer cron [%s] running more than %v", c.Name, c.MaxRuntime)
+		})
+	}
+	defer func() {
+		atomic.AddInt32(&c.running, -1)
+		if warn != nil {
+			warn.Stop()
+		}
+		p := recover()
+		if c.hist != nil {
+			c.hist.add(CronRun{Start: start, Duration: time.Since(start), Panic: p})
+		}
+		if p != nil {
+			var buf [4096]byte
+			n := runtime.Stack(buf[:], false)
+			_ = log.Errorf("Timer cron [%s] panic: %v\n%s", c.Name, p, buf[:n])
+		}
+	}()
+	e.a.OnTimer(e.h, e.data)
+}
+
+// NewCronWithOptions 创建带选项的循环定时方法
+// o 方法执行节点，为nil时在默认节点上执行；节点开始关闭时自动终止
+// expr 定时执行规则
+// f 定时执行的方法
+// opt 选项，为nil时和 NewCron 相同
+// 返回延时方法的id,用来提前终止执行,和expr配置错误
+func NewCronWithOptions(o *basic.Object, expr string, f func(), opt *CronOptions) (Handle, error) {
+	s, err := NewCronExpr(expr)
+	if err != nil {
+		return 0, err
+	}
+	e := newEntry(o, kindCron, ActionWrapper(func(h Handle, ud interface{}) {
+		f()
+	}), nil)
+	e.expr = s
+	if opt != nil {
+		e.opt = newCronOption(e.h, opt)
+	}
+	now := time.Now()
+	next := s.Next(now)
+	if next.IsZero() {
+		return e.h, nil
+	}
+	return e.start(next.Sub(now) + e.jitter()), nil
+}
+
+// CronHistory 获取 cron 定时方法最近的执行记录，按时间顺序排序
+// 定时方法结束后不能再通过 id 获取，可以通过名称获取
+func CronHistory(h Handle) []CronRun {
+	v, ok := histories.Load(h)
+	if !ok {
+		return nil
+	}
+	return v.(*history).get()
+}
+
+// CronHistoryByName 根据名称获取 cron 定时方法最近的执行记录，按时间顺序排序
+func CronHistoryByName(name string) []CronRun {
+	v, ok := namedHistories.Load(name)
+	if !ok {
+		return nil
+	}
+	return v.(*history).get()
+}
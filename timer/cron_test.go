@@ -0,0 +1,81 @@
+package timer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/timer"
+)
+
+func TestNewCronWithOptions(t *testing.T) {
+	o := basic.NewObject(101, "cron", new(basic.Options), nil)
+	o.Run()
+	basic.Root.AddChild(o)
+	defer o.Close()
+
+	slow, err := timer.NewCronWithOptions(o, "* * * * * *", func() {
+		time.Sleep(time.Millisecond * 1500)
+	}, &timer.CronOptions{
+		Name:          "slow",
+		SkipIfRunning: true,
+		MaxRuntime:    time.Second,
+		History:       10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := timer.NewCronWithOptions(basic.Root, "* * * * * *", func() {
+		panic("cron panic")
+	}, &timer.CronOptions{
+		Name:    "panic",
+		Jitter:  time.Millisecond * 100,
+		History: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer timer.Stop(h)
+	time.Sleep(time.Millisecond * 3500)
+	runs := timer.CronHistory(slow)
+	timer.StopObject(o)
+
+	skipped := 0
+	for _, v := range runs {
+		if v.Skipped {
+			skipped++
+		} else if v.Duration < time.Millisecond*1500 {
+			t.Error("1", v)
+		}
+	}
+	if skipped == 0 || len(runs) == skipped {
+		t.Error("2", runs)
+	}
+
+	runs = timer.CronHistoryByName("panic")
+	if len(runs) != 2 || runs[0].Panic != "cron panic" || !runs[0].Start.Before(runs[1].Start) {
+		t.Error("3", runs)
+	}
+	if timer.CronHistory(slow) != nil || len(timer.CronHistoryByName("slow")) == 0 {
+		t.Error("4")
+	}
+}
+
+func TestCronSkipIfRunningNoObject(t *testing.T) {
+	// 没有执行节点时不执行，也不能一直算作正在执行
+	timer.SetObject(nil)
+	h, err := timer.NewCronWithOptions(nil, "* * * * * *", func() {}, &timer.CronOptions{
+		SkipIfRunning: true,
+		History:       10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer timer.Stop(h)
+	time.Sleep(time.Millisecond * 2500)
+	for _, v := range timer.CronHistory(h) {
+		if v.Skipped {
+			t.Fatal("skipped", timer.CronHistory(h))
+		}
+	}
+}
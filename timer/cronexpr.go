@@ -9,14 +9,31 @@ import (
 	"time"
 )
 
-// Field name   | Mandatory? | Allowed values | Allowed special characters
-// ----------   | ---------- | -------------- | --------------------------
-// Seconds      | No         | 0-59           | * / , -
-// Minutes      | Yes        | 0-59           | * / , -
-// Hours        | Yes        | 0-23           | * / , -
-// Day of month | Yes        | 1-31           | * / , -
-// Month        | Yes        | 1-12           | * / , -
-// Day of week  | Yes        | 0-6            | * / , -
+// Field name   | Mandatory? | Allowed values  | Allowed special characters
+// ----------   | ---------- | --------------  | --------------------------
+// Seconds      | No         | 0-59            | * / , -
+// Minutes      | Yes        | 0-59            | * / , -
+// Hours        | Yes        | 0-23            | * / , -
+// Day of month | Yes        | 1-31            | * / , - ? L W
+// Month        | Yes        | 1-12 or JAN-DEC | * / , -
+// Day of week  | Yes        | 0-7 or SUN-SAT  | * / , - ? L #
+// Year         | No         | 1970-2099       | * / , -
+//
+// 5个字段时没有秒，7个字段时最后一个字段是年
+// ?   只用于日期和星期，表示不指定，和 * 相同
+// L   日期中表示每月最后一天，L-3 表示最后一天的前3天，LW 表示每月最后一个工作日
+// L   星期中单独使用表示星期六，5L 表示每月最后一个星期五
+// W   日期中 15W 表示离15号最近的工作日，不会跨月
+// #   星期中 5#3 表示每月第三个星期五
+// 星期的 0 和 7 都表示星期日
+//
+// 预定义的表达式：
+// @yearly(@annually) @monthly @weekly @daily(@midnight) @hourly
+// @every <duration> 每隔一段时间执行一次，例如 @every 1h30m，时长最小为1秒
+//
+// 时区：
+// 表达式前加 TZ=<时区> 或 CRON_TZ=<时区>，例如 TZ=Asia/Shanghai 0 0 8 * * *，默认使用本地时区
+// 夏令时开始时不存在的时间在跳过的时段结束时执行一次，结束时重复的时间只在第一次出现时执行
 type CronExpr struct {
 	sec   uint64
 	min   uint64
@@ -24,13 +41,146 @@ type CronExpr struct {
 	dom   uint64
 	month uint64
 	dow   uint64
+	// domRules 日期中的 L 和 W
+	domRules []domRule
+	// dowRules 星期中的 L 和 #
+	dowRules []dowRule
+	// year 年份，下标是年份减 yearMin；为nil时不限制
+	year []bool
+	// every @every 的间隔时长，不为0时忽略其它字段
+	every time.Duration
+	// loc 时区
+	loc *time.Location
+}
+
+const (
+	yearMin = 1970
+	yearMax = 2099
+
+	domAll = 0xfffffffe
+	dowAll = 0x7f
+)
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var dowNames = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// domRule 日期规则
+type domRule struct {
+	last    bool // 从月末开始计算
+	offset  int  // last 为true时是距离最后一天的天数，否则是日期
+	weekday bool // 最近的工作日
+}
+
+func (r domRule) match(t time.Time) bool {
+	days := daysIn(t)
+	day := r.offset
+	if r.last {
+		day = days - r.offset
+	}
+	if day < 1 || day > days {
+		return false
+	}
+	if r.weekday {
+		switch time.Date(t.Year(), t.Month(), day, 0, 0, 0, 0, time.UTC).Weekday() {
+		case time.Saturday:
+			if day == 1 {
+				day += 2
+			} else {
+				day--
+			}
+		case time.Sunday:
+			if day == days {
+				day -= 2
+			} else {
+				day++
+			}
+		}
+	}
+	return t.Day() == day
+}
+
+// dowRule 星期规则
+type dowRule struct {
+	weekday int
+	nth     int // 每月第几个，0表示最后一个
+}
+
+func (r dowRule) match(t time.Time) bool {
+	if int(t.Weekday()) != r.weekday {
+		return false
+	}
+	if r.nth == 0 {
+		return t.Day()+7 > daysIn(t)
+	}
+	return (t.Day()-1)/7+1 == r.nth
+}
+
+// daysIn t 所在月份的天数
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
 }
 
 // goroutine safe
 func NewCronExpr(expr string) (cronExpr *CronExpr, err error) {
+	src := expr
+	loc := time.Local
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		i := strings.IndexAny(expr, " \t")
+		if i < 0 {
+			err = fmt.Errorf("invalid expr %v: missing fields after time zone", src)
+			return
+		}
+		name := expr[strings.Index(expr, "=")+1 : i]
+		loc, err = time.LoadLocation(name)
+		if err != nil {
+			err = fmt.Errorf("invalid expr %v: %v", src, err)
+			return
+		}
+		expr = strings.TrimSpace(expr[i:])
+	}
+
+	if strings.HasPrefix(expr, "@every") {
+		var d time.Duration
+		d, err = time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every")))
+		if err != nil {
+			err = fmt.Errorf("invalid expr %v: %v", src, err)
+			return
+		}
+		if d < time.Second {
+			d = time.Second
+		}
+		cronExpr = &CronExpr{every: d - d%time.Second, loc: loc}
+		return
+	}
+	if strings.HasPrefix(expr, "@") {
+		v, ok := macros[expr]
+		if !ok {
+			err = fmt.Errorf("invalid expr %v: unknown macro", src)
+			return
+		}
+		expr = v
+	}
+
 	fields := strings.Fields(expr)
-	if len(fields) != 5 && len(fields) != 6 {
-		err = fmt.Errorf("invalid expr %v: expected 5 or 6 fields, got %v", expr, len(fields))
+	if len(fields) < 5 || len(fields) > 7 {
+		err = fmt.Errorf("invalid expr %v: expected 5 to 7 fields, got %v", src, len(fields))
 		return
 	}
 
@@ -38,51 +188,71 @@ func NewCronExpr(expr string) (cronExpr *CronExpr, err error) {
 		fields = append([]string{"0"}, fields...)
 	}
 
-	cronExpr = new(CronExpr)
+	cronExpr = &CronExpr{loc: loc}
 	// Seconds
-	cronExpr.sec, err = parseCronField(fields[0], 0, 59)
+	cronExpr.sec, err = parseCronField(fields[0], 0, 59, nil)
 	if err != nil {
 		goto onError
 	}
 	// Minutes
-	cronExpr.min, err = parseCronField(fields[1], 0, 59)
+	cronExpr.min, err = parseCronField(fields[1], 0, 59, nil)
 	if err != nil {
 		goto onError
 	}
 	// Hours
-	cronExpr.hour, err = parseCronField(fields[2], 0, 23)
+	cronExpr.hour, err = parseCronField(fields[2], 0, 23, nil)
 	if err != nil {
 		goto onError
 	}
 	// Day of month
-	cronExpr.dom, err = parseCronField(fields[3], 1, 31)
+	cronExpr.dom, cronExpr.domRules, err = parseDomField(fields[3])
 	if err != nil {
 		goto onError
 	}
 	// Month
-	cronExpr.month, err = parseCronField(fields[4], 1, 12)
+	cronExpr.month, err = parseCronField(fields[4], 1, 12, monthNames)
 	if err != nil {
 		goto onError
 	}
 	// Day of week
-	cronExpr.dow, err = parseCronField(fields[5], 0, 6)
+	cronExpr.dow, cronExpr.dowRules, err = parseDowField(fields[5])
 	if err != nil {
 		goto onError
 	}
+	// Year
+	if len(fields) == 7 && fields[6] != "*" {
+		cronExpr.year = make([]bool, yearMax-yearMin+1)
+		err = parseCronSet(fields[6], yearMin, yearMax, nil, func(i int) {
+			cronExpr.year[i-yearMin] = true
+		})
+		if err != nil {
+			goto onError
+		}
+	}
 	return
 
 onError:
-	err = fmt.Errorf("invalid expr %v: %v", expr, err)
+	cronExpr = nil
+	err = fmt.Errorf("invalid expr %v: %v", src, err)
 	return
 }
 
+// parseCronValue 解析数字或名称
+func parseCronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	return strconv.Atoi(s)
+}
+
 // 1. *
 // 2. num
 // 3. num-num
 // 4. */num
 // 5. num/num (means num-max/num)
 // 6. num-num/num
-func parseCronField(field string, min int, max int) (cronField uint64, err error) {
+// num 也可以是 names 中的名称
+func parseCronSet(field string, min int, max int, names map[string]int, set func(i int)) (err error) {
 	fields := strings.Split(field, ",")
 	for _, field := range fields {
 		rangeAndIncr := strings.Split(field, "/")
@@ -99,7 +269,7 @@ func parseCronField(field string, min int, max int) (cronField uint64, err error
 		}
 
 		var start, end int
-		if startAndEnd[0] == "*" {
+		if startAndEnd[0] == "*" || startAndEnd[0] == "?" {
 			if len(startAndEnd) != 1 {
 				err = fmt.Errorf("invalid range: %v", rangeAndIncr[0])
 				return
@@ -108,7 +278,7 @@ func parseCronField(field string, min int, max int) (cronField uint64, err error
 			end = max
 		} else {
 			// start
-			start, err = strconv.Atoi(startAndEnd[0])
+			start, err = parseCronValue(startAndEnd[0], names)
 			if err != nil {
 				err = fmt.Errorf("invalid range: %v", rangeAndIncr[0])
 				return
@@ -121,7 +291,7 @@ func parseCronField(field string, min int, max int) (cronField uint64, err error
 					end = start
 				}
 			} else {
-				end, err = strconv.Atoi(startAndEnd[1])
+				end, err = parseCronValue(startAndEnd[1], names)
 				if err != nil {
 					err = fmt.Errorf("invalid range: %v", rangeAndIncr[0])
 					return
@@ -158,36 +328,217 @@ func parseCronField(field string, min int, max int) (cronField uint64, err error
 			}
 		}
 
-		// cronField
-		if incr == 1 {
-			cronField |= ^(math.MaxUint64 << uint(end+1)) & (math.MaxUint64 << uint(start))
-		} else {
-			for i := start; i <= end; i += incr {
-				cronField |= 1 << uint(i)
+		for i := start; i <= end; i += incr {
+			set(i)
+		}
+	}
+
+	return
+}
+
+func parseCronField(field string, min int, max int, names map[string]int) (cronField uint64, err error) {
+	if field == "*" || field == "?" {
+		cronField = ^(math.MaxUint64 << uint(max+1)) & (math.MaxUint64 << uint(min))
+		return
+	}
+	err = parseCronSet(field, min, max, names, func(i int) {
+		cronField |= 1 << uint(i)
+	})
+	return
+}
+
+// parseDomField 解析日期，L 和 W 的部分解析为 domRule
+func parseDomField(field string) (cronField uint64, rules []domRule, err error) {
+	var normal []string
+	for _, v := range strings.Split(field, ",") {
+		var r domRule
+		s := strings.ToUpper(v)
+		switch {
+		case s == "L":
+			r = domRule{last: true}
+		case s == "LW":
+			r = domRule{last: true, weekday: true}
+		case strings.HasPrefix(s, "L-"):
+			r.last = true
+			r.offset, err = strconv.Atoi(s[2:])
+			if err != nil || r.offset < 1 || r.offset > 30 {
+				err = fmt.Errorf("invalid last day offset: %v", v)
+				return
+			}
+		case strings.HasSuffix(s, "W"):
+			r.weekday = true
+			r.offset, err = strconv.Atoi(s[:len(s)-1])
+			if err != nil || r.offset < 1 || r.offset > 31 {
+				err = fmt.Errorf("invalid weekday: %v", v)
+				return
 			}
+		default:
+			normal = append(normal, v)
+			continue
 		}
+		rules = append(rules, r)
+	}
+	if len(normal) > 0 {
+		cronField, err = parseCronField(strings.Join(normal, ","), 1, 31, nil)
 	}
+	return
+}
 
+// parseDowField 解析星期，L 和 # 的部分解析为 dowRule
+func parseDowField(field string) (cronField uint64, rules []dowRule, err error) {
+	var normal []string
+	for _, v := range strings.Split(field, ",") {
+		var r dowRule
+		s := strings.ToUpper(v)
+		switch {
+		case s == "L":
+			cronField |= 1 << uint(time.Saturday)
+			continue
+		case strings.HasSuffix(s, "L"):
+			r.weekday, err = parseCronValue(s[:len(s)-1], dowNames)
+		case strings.Contains(s, "#"):
+			i := strings.Index(s, "#")
+			r.weekday, err = parseCronValue(s[:i], dowNames)
+			if err == nil {
+				r.nth, err = strconv.Atoi(s[i+1:])
+				if err == nil && (r.nth < 1 || r.nth > 5) {
+					err = fmt.Errorf("out of range [1, 5]: %v", s[i+1:])
+				}
+			}
+		default:
+			normal = append(normal, v)
+			continue
+		}
+		if err == nil && (r.weekday < 0 || r.weekday > 7) {
+			err = fmt.Errorf("out of range [0, 7]: %v", v)
+		}
+		if err != nil {
+			err = fmt.Errorf("invalid day of week: %v", v)
+			return
+		}
+		r.weekday %= 7
+		rules = append(rules, r)
+	}
+	if len(normal) > 0 {
+		var f uint64
+		f, err = parseCronField(strings.Join(normal, ","), 0, 7, dowNames)
+		if err != nil {
+			return
+		}
+		// 7 也表示星期日
+		if f&(1<<7) != 0 {
+			f = f&^(1<<7) | 1
+		}
+		cronField |= f
+	}
 	return
 }
 
+func (e *CronExpr) matchDom(t time.Time) bool {
+	if 1<<uint(t.Day())&e.dom != 0 {
+		return true
+	}
+	for _, v := range e.domRules {
+		if v.match(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *CronExpr) matchDow(t time.Time) bool {
+	if 1<<uint(t.Weekday())&e.dow != 0 {
+		return true
+	}
+	for _, v := range e.dowRules {
+		if v.match(t) {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *CronExpr) matchDay(t time.Time) bool {
 	// day-of-month blank
-	if e.dom == 0xfffffffe {
-		return 1<<uint(t.Weekday())&e.dow != 0
+	if e.dom == domAll && len(e.domRules) == 0 {
+		return e.matchDow(t)
 	}
 
 	// day-of-week blank
-	if e.dow == 0x7f {
-		return 1<<uint(t.Day())&e.dom != 0
+	if e.dow == dowAll && len(e.dowRules) == 0 {
+		return e.matchDom(t)
+	}
+
+	return e.matchDow(t) || e.matchDom(t)
+}
+
+func (e *CronExpr) matchYear(year int) bool {
+	if e.year == nil {
+		return true
+	}
+	if year < yearMin || year > yearMax {
+		return false
 	}
+	return e.year[year-yearMin]
+}
 
-	return 1<<uint(t.Weekday())&e.dow != 0 ||
-		1<<uint(t.Day())&e.dom != 0
+// Location 表达式的时区
+func (e *CronExpr) Location() *time.Location {
+	return e.loc
 }
 
 // goroutine safe
+// 返回 t 之后下一次执行的时间，时区为表达式的时区；没有时返回零值
 func (e *CronExpr) Next(t time.Time) time.Time {
+	if e.every > 0 {
+		return t.Add(e.every - time.Duration(t.Nanosecond()))
+	}
+
+	loc := e.loc
+	if loc == nil {
+		loc = time.Local
+	}
+	// 用 UTC 表示时区中的墙上时间，按墙上时间查找，不受夏令时影响
+	w := toWall(t.In(loc))
+	for {
+		w = e.nextWall(w)
+		if w.IsZero() {
+			return w
+		}
+		ret := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), 0, loc)
+		if !toWall(ret).Equal(w) {
+			// 夏令时开始时跳过的时间，在跳过的时段结束时执行
+			ret = gapEnd(w, ret, loc)
+		}
+		// 夏令时结束时重复的时间 time.Date 返回第一次出现的时间，已经过去时继续查找
+		if ret.After(t) {
+			return ret
+		}
+	}
+}
+
+// toWall 用 UTC 表示的墙上时间
+func toWall(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// gapEnd 查找墙上时间第一次不早于 w 的时间，即跳过时段的结束时间
+func gapEnd(w, near time.Time, loc *time.Location) time.Time {
+	lo := near.Add(-3 * time.Hour).Unix()
+	hi := near.Add(3 * time.Hour).Unix()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if toWall(time.Unix(mid, 0).In(loc)).Before(w) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return time.Unix(lo, 0).In(loc)
+}
+
+// nextWall 返回墙上时间 t 之后第一个满足表达式的墙上时间
+func (e *CronExpr) nextWall(t time.Time) time.Time {
 	// the upcoming second
 	t = t.Truncate(time.Second).Add(time.Second)
 
@@ -196,9 +547,19 @@ func (e *CronExpr) Next(t time.Time) time.Time {
 
 retry:
 	// Year
-	if t.Year() > year+1 {
+	if e.year == nil {
+		// 2月29日最多需要等待8年
+		if t.Year() > year+8 {
+			return time.Time{}
+		}
+	} else if t.Year() > yearMax {
 		return time.Time{}
 	}
+	if !e.matchYear(t.Year()) {
+		t = time.Date(t.Year()+1, time.January, 1, 0, 0, 0, 0, t.Location())
+		initFlag = true
+		goto retry
+	}
 
 	// Month
 	for 1<<uint(t.Month())&e.month == 0 {
@@ -0,0 +1,92 @@
+package timer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skeletongo/core/timer"
+)
+
+func TestCronExpr_Next(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	const layout = "2006-01-02 15:04:05 MST"
+	tests := []struct {
+		expr string
+		from string
+		want []string
+	}{
+		// 名称和 ?
+		{"TZ=UTC 0 0 12 ? JAN-MAR MON", "2021-01-01 00:00:00 UTC", []string{"2021-01-04 12:00:00 UTC", "2021-01-11 12:00:00 UTC"}},
+		// 每月最后一天，最后一天的前2天
+		{"TZ=UTC 0 0 0 L * ?", "2021-01-31 00:00:00 UTC", []string{"2021-02-28 00:00:00 UTC", "2021-03-31 00:00:00 UTC"}},
+		{"TZ=UTC 0 0 0 L-2 * ?", "2021-02-01 00:00:00 UTC", []string{"2021-02-26 00:00:00 UTC"}},
+		// 离1号最近的工作日，2021-05-01是星期六
+		{"TZ=UTC 0 0 0 1W * ?", "2021-04-10 00:00:00 UTC", []string{"2021-05-03 00:00:00 UTC"}},
+		// 离15号最近的工作日，2021-05-15是星期六
+		{"TZ=UTC 0 0 0 15W * ?", "2021-05-01 00:00:00 UTC", []string{"2021-05-14 00:00:00 UTC"}},
+		// 每月最后一个工作日，2021-07-31是星期六
+		{"TZ=UTC 0 0 0 LW * ?", "2021-07-01 00:00:00 UTC", []string{"2021-07-30 00:00:00 UTC"}},
+		// 每月第三个星期五，最后一个星期五
+		{"TZ=UTC 0 0 0 ? * FRI#3", "2021-01-01 00:00:00 UTC", []string{"2021-01-15 00:00:00 UTC", "2021-02-19 00:00:00 UTC"}},
+		{"TZ=UTC 0 0 0 ? * 5L", "2021-01-01 00:00:00 UTC", []string{"2021-01-29 00:00:00 UTC", "2021-02-26 00:00:00 UTC"}},
+		// 星期日
+		{"TZ=UTC 0 0 0 ? * 7", "2021-01-01 00:00:00 UTC", []string{"2021-01-03 00:00:00 UTC"}},
+		// 年份
+		{"TZ=UTC 0 0 0 29 FEB ? 2023-2030", "2021-01-01 00:00:00 UTC", []string{"2024-02-29 00:00:00 UTC", "2028-02-29 00:00:00 UTC", ""}},
+		// 预定义
+		{"TZ=UTC @monthly", "2021-01-15 00:00:00 UTC", []string{"2021-02-01 00:00:00 UTC"}},
+		{"TZ=UTC @every 90m", "2021-01-15 00:00:00 UTC", []string{"2021-01-15 01:30:00 UTC", "2021-01-15 03:00:00 UTC"}},
+		// 时区
+		{"TZ=Asia/Shanghai 0 0 8 * * *", "2021-01-01 00:00:00 UTC", []string{"2021-01-02 08:00:00 CST"}},
+		// 夏令时开始，2:30 不存在，在 3:00 执行
+		{"TZ=America/New_York 0 30 2 * * *", "2021-03-13 12:00:00 EST", []string{"2021-03-14 03:00:00 EDT", "2021-03-15 02:30:00 EDT"}},
+		// 夏令时结束，1:30 只执行一次
+		{"TZ=America/New_York 0 30 1 * * *", "2021-11-07 00:00:00 EDT", []string{"2021-11-07 01:30:00 EDT", "2021-11-08 01:30:00 EST"}},
+	}
+	for _, v := range tests {
+		e, err := timer.NewCronExpr(v.expr)
+		if err != nil {
+			t.Error(v.expr, err)
+			continue
+		}
+		from, err := time.ParseInLocation(layout, v.from, e.Location())
+		if err != nil {
+			from, err = time.ParseInLocation(layout, v.from, ny)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range v.want {
+			from = e.Next(from)
+			got := ""
+			if !from.IsZero() {
+				got = from.Format(layout)
+			}
+			if got != want {
+				t.Error(v.expr, "want", want, "got", got)
+				break
+			}
+		}
+	}
+}
+
+func TestNewCronExpr_Error(t *testing.T) {
+	for _, v := range []string{
+		"* * * *",
+		"* * * * * * * *",
+		"@unknown",
+		"@every x",
+		"TZ=Nowhere/City * * * * *",
+		"0 0 0 L-40 * ?",
+		"0 0 0 ? * FRI#6",
+		"0 0 0 ? * FOO",
+		"0 0 0 1 * ? 1900",
+	} {
+		if _, err := timer.NewCronExpr(v); err == nil {
+			t.Error(v)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package timer
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/skeletongo/core/basic"
+)
+
+// owner 一个执行节点的所有定时器
+type owner struct {
+	sync.Mutex
+	entries map[Handle]*entry
+	closed  bool // 节点已经开始关闭
+}
+
+// owners 按执行节点保存定时器; key:*basic.Object,value:*owner
+var owners = new(sync.Map)
+
+// track 记录执行节点的定时器，节点开始关闭时终止它的所有定时器
+// 节点已经开始关闭时返回false
+func track(e *entry) bool {
+	if e.o == nil {
+		return true
+	}
+	v, loaded := owners.LoadOrStore(e.o, &owner{entries: make(map[Handle]*entry)})
+	w := v.(*owner)
+	if !loaded && !e.o.AddCloseHook(onObjectClose) {
+		w.close(e.o)
+		return false
+	}
+	w.Lock()
+	defer w.Unlock()
+	if w.closed {
+		return false
+	}
+	w.entries[e.h] = e
+	return true
+}
+
+// untrack 定时器结束
+func untrack(e *entry) {
+	if e.o == nil {
+		return
+	}
+	v, ok := owners.Load(e.o)
+	if !ok {
+		return
+	}
+	w := v.(*owner)
+	w.Lock()
+	delete(w.entries, e.h)
+	w.Unlock()
+}
+
+// onObjectClose 节点开始关闭，终止它的所有定时器
+func onObjectClose(o *basic.Object) {
+	v, ok := owners.Load(o)
+	if !ok {
+		return
+	}
+	v.(*owner).close(o)
+}
+
+// close 终止所有定时器，之后添加的定时器直接终止
+func (w *owner) close(o *basic.Object) {
+	w.Lock()
+	w.closed = true
+	entries := w.entries
+	w.entries = make(map[Handle]*entry)
+	w.Unlock()
+	owners.Delete(o)
+
+	for _, e := range entries {
+		e.stop()
+	}
+}
+
+// ObjectTimers 获取执行节点上所有没有结束的定时器，按创建顺序排序
+// o 执行节点，为nil时为默认节点
+func ObjectTimers(o *basic.Object) []Handle {
+	if o == nil {
+		o = defaultObject
+	}
+	v, ok := owners.Load(o)
+	if !ok {
+		return nil
+	}
+	w := v.(*owner)
+	w.Lock()
+	ret := make([]Handle, 0, len(w.entries))
+	for h := range w.entries {
+		ret = append(ret, h)
+	}
+	w.Unlock()
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i] < ret[j]
+	})
+	return ret
+}
+
+// StopObject 终止执行节点上的所有定时器
+// o 执行节点，为nil时为默认节点
+func StopObject(o *basic.Object) {
+	for _, h := range ObjectTimers(o) {
+		Stop(h)
+	}
+}
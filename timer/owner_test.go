@@ -0,0 +1,91 @@
+package timer_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/timer"
+)
+
+func TestObjectTimers(t *testing.T) {
+	o := basic.NewObject(100, "owner", new(basic.Options), nil)
+	o.Run()
+	basic.Root.AddChild(o)
+
+	var n int32
+	a := timer.ActionWrapper(func(h timer.Handle, ud interface{}) {
+		atomic.AddInt32(&n, 1)
+	})
+	h1 := timer.NewTimer(o, a, nil, time.Millisecond*50)
+	h2 := timer.NewTicker(o, a, nil, time.Millisecond*10)
+	h3, _ := timer.NewCron(o, "* * * * * *", func() {})
+	other := timer.NewTimer(basic.Root, a, nil, time.Hour)
+	defer timer.Stop(other)
+
+	hs := timer.ObjectTimers(o)
+	if len(hs) != 3 || hs[0] != h1 || hs[1] != h2 || hs[2] != h3 {
+		t.Fatal("1", hs)
+	}
+
+	o.Close()
+	time.Sleep(time.Millisecond * 20)
+	if len(timer.ObjectTimers(o)) != 0 {
+		t.Error("2", timer.ObjectTimers(o))
+	}
+	if _, ok := timer.Remaining(h1); ok {
+		t.Error("3")
+	}
+	if _, ok := timer.Remaining(other); !ok {
+		t.Error("4")
+	}
+	v := atomic.LoadInt32(&n)
+	time.Sleep(time.Millisecond * 60)
+	if atomic.LoadInt32(&n) != v {
+		t.Error("5 fired after owner closed")
+	}
+
+	// 节点关闭后创建的定时器直接终止
+	h4 := timer.NewTimer(o, a, nil, time.Millisecond)
+	if _, ok := timer.Remaining(h4); ok {
+		t.Error("6")
+	}
+}
+
+func TestObjectCloseQueued(t *testing.T) {
+	o := basic.NewObject(103, "owner_queued", new(basic.Options), nil)
+	o.Run()
+	basic.Root.AddChild(o)
+	// 子节点没有关闭时执行节点一直处于关闭中
+	c := basic.NewObject(104, "owner_child", new(basic.Options), nil)
+	c.Run()
+	o.AddChild(c)
+	block := func(o *basic.Object, gate chan struct{}) {
+		o.Send(basic.CommandWrapper(func(*basic.Object) error {
+			<-gate
+			return nil
+		}))
+	}
+	gate, childGate := make(chan struct{}), make(chan struct{})
+	block(o, gate)
+	block(c, childGate)
+	defer close(childGate)
+
+	// 计时结束时节点还没有开始关闭，等待执行时节点开始关闭
+	var n int32
+	timer.NewTimer(o, timer.ActionWrapper(func(h timer.Handle, ud interface{}) {
+		atomic.AddInt32(&n, 1)
+	}), nil, time.Millisecond*20)
+	o.Close()
+	time.Sleep(time.Millisecond * 60)
+	close(gate)
+
+	time.Sleep(time.Millisecond * 50)
+	if atomic.LoadInt32(&n) != 0 {
+		t.Error("1 fired timer ran after owner started closing")
+	}
+	if len(timer.ObjectTimers(o)) != 0 {
+		t.Error("2", timer.ObjectTimers(o))
+	}
+}
@@ -0,0 +1,412 @@
+package timer
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/log"
+)
+
+var (
+	ErrNoStore     = errors.New("Timer store not set ")
+	ErrUnknownKind = errors.New("Timer persistent kind not register ")
+)
+
+// 错过执行时间的处理方式，用于进程停止期间到期的持久化定时器
+const (
+	MisfireOnce = iota // 立即执行一次
+	MisfireAll         // 每个错过的执行时间都执行一次，最多执行 MaxMisfire 次
+	MisfireSkip        // 不执行，延时方法直接结束，cron 定时方法等待下次执行时间
+)
+
+// MaxMisfire MisfireAll 最多补充执行的次数
+var MaxMisfire = 1000
+
+// Record 持久化定时器的保存记录
+type Record struct {
+	Name    string        // 名称，唯一
+	Kind    string        // 处理方法类型，对应 RegisterPersistent 的注册
+	Expr    string        `json:",omitempty"` // cron 表达式，为空时是延时方法
+	Next    time.Time     // 下次执行时间
+	Misfire int           // 错过执行时间的处理方式
+	Data    []byte        `json:",omitempty"` // 处理方法需要的数据
+	Paused  bool          `json:",omitempty"` // 是否暂停中，恢复后仍然是暂停的
+	Remain  time.Duration `json:",omitempty"` // 暂停时剩余的时长
+}
+
+// Store 持久化定时器的存储
+type Store interface {
+	// Load 读取所有记录
+	Load() ([]*Record, error)
+	// Save 保存记录，名称相同时覆盖
+	Save(r *Record) error
+	// Delete 删除记录
+	Delete(name string) error
+}
+
+// FileStore 使用一个 json 文件保存所有记录，每次修改都重写整个文件
+type FileStore struct {
+	sync.Mutex
+	path    string
+	records map[string]*Record
+}
+
+// NewFileStore 创建文件存储
+// path 文件路径
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path:    path,
+		records: make(map[string]*Record),
+	}
+}
+
+func (s *FileStore) Load() ([]*Record, error) {
+	s.Lock()
+	defer s.Unlock()
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ret []*Record
+	if len(b) > 0 {
+		if err = json.Unmarshal(b, &ret); err != nil {
+			return nil, err
+		}
+	}
+	s.records = make(map[string]*Record)
+	for _, v := range ret {
+		r := *v
+		s.records[v.Name] = &r
+	}
+	return ret, nil
+}
+
+func (s *FileStore) Save(r *Record) error {
+	s.Lock()
+	defer s.Unlock()
+	v := *r
+	s.records[r.Name] = &v
+	return s.flush()
+}
+
+func (s *FileStore) Delete(name string) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.records[name]; !ok {
+		return nil
+	}
+	delete(s.records, name)
+	return s.flush()
+}
+
+// flush 先写入临时文件再替换，避免写入中断时丢失所有记录
+func (s *FileStore) flush() error {
+	list := make([]*Record, 0, len(s.records))
+	for _, v := range s.records {
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// persistAction 持久化定时器的处理方法
+type persistAction struct {
+	o *basic.Object
+	a Action
+}
+
+var persistActions = make(map[string]*persistAction)
+
+// RegisterPersistent 注册持久化定时器的处理方法，需要在 Restore 和创建持久化定时器前注册
+// kind 处理方法类型
+// o 方法执行节点，为nil时在默认节点上执行
+// a 方法实例，OnTimer 的 ud 参数是创建定时器时的 data，类型为 []byte
+func RegisterPersistent(kind string, o *basic.Object, a Action) {
+	if a == nil {
+		return
+	}
+	if _, exist := persistActions[kind]; exist {
+		panic("repeat register persistent timer:" + kind)
+	}
+	persistActions[kind] = &persistAction{o: o, a: a}
+}
+
+var store Store
+
+// persistents 所有持久化定时器; key:名称,value:*entry
+var persistents = new(sync.Map)
+
+// persist 定时器的持久化信息
+type persist struct {
+	name    string
+	kind    string
+	expr    string
+	misfire int
+	data    []byte
+}
+
+// record 保存记录，需要加锁
+func (e *entry) record() *Record {
+	r := &Record{
+		Name:    e.p.name,
+		Kind:    e.p.kind,
+		Expr:    e.p.expr,
+		Next:    e.next,
+		Misfire: e.p.misfire,
+		Data:    e.p.data,
+	}
+	if e.paused {
+		r.Next = time.Now().Add(e.remain)
+		r.Paused = true
+		r.Remain = e.remain
+	}
+	return r
+}
+
+func (p *persist) save(e *entry) {
+	e.Lock()
+	r := e.record()
+	e.Unlock()
+	saveRecord(r)
+}
+
+// remove 删除保存的记录，名称已经被新的定时器使用时不删除
+func (p *persist) remove(e *entry) {
+	if v, ok := persistents.Load(p.name); !ok || v.(*entry) != e {
+		return
+	}
+	persistents.Delete(p.name)
+	if store == nil {
+		return
+	}
+	if err := store.Delete(p.name); err != nil {
+		_ = log.Errorf("Timer [%s] delete record error: %v", p.name, err)
+	}
+}
+
+func saveRecord(r *Record) {
+	if store == nil {
+		return
+	}
+	if err := store.Save(r); err != nil {
+		_ = log.Errorf("Timer [%s] save record error: %v", r.Name, err)
+	}
+}
+
+// newPersistEntry 创建持久化定时器，名称相同的定时器会被终止
+func newPersistEntry(name, kind string, data []byte, misfire int) (*entry, error) {
+	if store == nil {
+		return nil, ErrNoStore
+	}
+	pa, ok := persistActions[kind]
+	if !ok {
+		return nil, ErrUnknownKind
+	}
+	e := newEntry(pa.o, kindTimer, pa.a, data)
+	e.p = &persist{
+		name:    name,
+		kind:    kind,
+		misfire: misfire,
+		data:    data,
+	}
+	if v, ok := persistents.Load(name); ok {
+		v.(*entry).stop()
+	}
+	persistents.Store(name, e)
+	return e, nil
+}
+
+// NewPersistentTimer 创建持久化的延时方法，进程重启后通过 Restore 恢复
+// name 名称，名称相同的定时器会被替换
+// kind 处理方法类型，需要先通过 RegisterPersistent 注册
+// data 处理方法需要的数据
+// interval 延时时长
+// misfire 进程停止期间到期时的处理方式 MisfireOnce,MisfireAll,MisfireSkip
+// 返回定时器的id,用来提前终止执行
+func NewPersistentTimer(name, kind string, data []byte, interval time.Duration, misfire int) (Handle, error) {
+	e, err := newPersistEntry(name, kind, data, misfire)
+	if err != nil {
+		return 0, err
+	}
+	e.interval = interval
+	saveRecord(&Record{
+		Name:    name,
+		Kind:    kind,
+		Next:    time.Now().Add(interval),
+		Misfire: misfire,
+		Data:    data,
+	})
+	return e.start(interval), nil
+}
+
+// NewPersistentCron 创建持久化的循环定时方法，进程重启后通过 Restore 恢复
+// name 名称，名称相同的定时器会被替换
+// kind 处理方法类型，需要先通过 RegisterPersistent 注册
+// expr 定时执行规则
+// data 处理方法需要的数据
+// misfire 进程停止期间错过执行时间时的处理方式 MisfireOnce,MisfireAll,MisfireSkip
+// 返回定时器的id,用来提前终止执行,和expr配置错误
+func NewPersistentCron(name, kind, expr string, data []byte, misfire int) (Handle, error) {
+	s, err := NewCronExpr(expr)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	next := s.Next(now)
+	if next.IsZero() {
+		return 0, nil
+	}
+	e, err := newPersistEntry(name, kind, data, misfire)
+	if err != nil {
+		return 0, err
+	}
+	e.kind = kindCron
+	e.expr = s
+	e.p.expr = expr
+	saveRecord(&Record{
+		Name:    name,
+		Kind:    kind,
+		Expr:    expr,
+		Next:    next,
+		Misfire: misfire,
+		Data:    data,
+	})
+	return e.start(next.Sub(now)), nil
+}
+
+// StopPersistent 终止持久化定时器并删除保存的记录
+func StopPersistent(name string) {
+	if v, ok := persistents.Load(name); ok {
+		Stop(v.(*entry).h)
+	}
+}
+
+// GetPersistent 获取持久化定时器的id
+func GetPersistent(name string) (Handle, bool) {
+	v, ok := persistents.Load(name)
+	if !ok {
+		return 0, false
+	}
+	return v.(*entry).h, true
+}
+
+// Restore 设置持久化定时器的存储，并恢复保存的定时器
+// 需要在注册处理方法和设置默认执行节点之后调用，例如在模块的 Init 方法中
+// 处理方法没有注册的记录会保留，不会恢复
+func Restore(s Store) error {
+	records, err := s.Load()
+	if err != nil {
+		return err
+	}
+	store = s
+	now := time.Now()
+	for _, r := range records {
+		if err = restore(r, now); err != nil {
+			_ = log.Errorf("Timer [%s] restore error: %v", r.Name, err)
+			continue
+		}
+		log.Infof("Timer [%s] restore, kind: %s, next: %v", r.Name, r.Kind, r.Next)
+	}
+	return nil
+}
+
+func restore(r *Record, now time.Time) error {
+	pa, ok := persistActions[r.Kind]
+	if !ok {
+		return ErrUnknownKind
+	}
+	e := newEntry(pa.o, kindTimer, pa.a, r.Data)
+	e.p = &persist{
+		name:    r.Name,
+		kind:    r.Kind,
+		expr:    r.Expr,
+		misfire: r.Misfire,
+		data:    r.Data,
+	}
+
+	if r.Paused {
+		// 暂停中的定时器不会错过执行时间，恢复后继续计算剩余的时长
+		if r.Expr != "" {
+			s, err := NewCronExpr(r.Expr)
+			if err != nil {
+				return err
+			}
+			e.kind = kindCron
+			e.expr = s
+		} else {
+			e.interval = r.Remain
+		}
+		persistents.Store(r.Name, e)
+		e.startPaused(r.Remain)
+		return nil
+	}
+
+	if r.Expr == "" {
+		d := r.Next.Sub(now)
+		if d < 0 && r.Misfire == MisfireSkip {
+			if err := store.Delete(r.Name); err != nil {
+				return err
+			}
+			return nil
+		}
+		e.interval = d
+		persistents.Store(r.Name, e)
+		e.start(d)
+		return nil
+	}
+
+	s, err := NewCronExpr(r.Expr)
+	if err != nil {
+		return err
+	}
+	e.kind = kindCron
+	e.expr = s
+	// 错过的执行次数
+	missed := 0
+	if !r.Next.After(now) {
+		switch r.Misfire {
+		case MisfireOnce:
+			missed = 1
+		case MisfireAll:
+			for t := r.Next; !t.IsZero() && !t.After(now) && missed < MaxMisfire; t = s.Next(t) {
+				missed++
+			}
+		}
+	}
+	next := s.Next(now)
+	if next.IsZero() {
+		if missed == 0 {
+			return store.Delete(r.Name)
+		}
+		// 没有下次执行时间，补充执行后和延时方法一样删除记录
+		e.kind = kindTimer
+		persistents.Store(r.Name, e)
+	} else {
+		persistents.Store(r.Name, e)
+		e.start(next.Sub(now))
+		e.p.save(e)
+	}
+	for i := 0; i < missed; i++ {
+		sendEntry(e.o, e, 0, time.Time{})
+	}
+	return nil
+}
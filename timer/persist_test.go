@@ -0,0 +1,119 @@
+package timer_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/timer"
+)
+
+func TestRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timer.json")
+	now := time.Now()
+	old := timer.NewFileStore(path)
+	for _, r := range []*timer.Record{
+		{Name: "once", Kind: "persist", Next: now.Add(-time.Hour), Misfire: timer.MisfireOnce, Data: []byte("once")},
+		{Name: "skip", Kind: "persist", Next: now.Add(-time.Hour), Misfire: timer.MisfireSkip, Data: []byte("skip")},
+		{Name: "later", Kind: "persist", Next: now.Add(time.Millisecond * 50), Data: []byte("later")},
+		{Name: "cron", Kind: "persist", Expr: "0 0 * * * *", Next: now.Add(-time.Hour * 3).Truncate(time.Hour), Misfire: timer.MisfireAll, Data: []byte("cron")},
+	} {
+		if err := old.Save(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	fired := make(map[string]int)
+	timer.RegisterPersistent("persist", basic.Root, timer.ActionWrapper(func(h timer.Handle, ud interface{}) {
+		mu.Lock()
+		fired[string(ud.([]byte))]++
+		mu.Unlock()
+	}))
+
+	s := timer.NewFileStore(path)
+	if err := timer.Restore(s); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 100)
+
+	mu.Lock()
+	if fired["once"] != 1 || fired["skip"] != 0 || fired["later"] != 1 || fired["cron"] < 3 || fired["cron"] > 4 {
+		t.Error("1", fired)
+	}
+	mu.Unlock()
+
+	// 延时方法执行后删除记录，cron 保留下次执行时间
+	records, err := timer.NewFileStore(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Name != "cron" || !records[0].Next.After(now) {
+		t.Fatal("2", records)
+	}
+
+	h, err := timer.NewPersistentTimer("new", "persist", []byte("new"), time.Hour, timer.MisfireOnce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := timer.GetPersistent("new"); !ok || v != h {
+		t.Error("3")
+	}
+	if _, err = timer.NewPersistentTimer("bad", "unknown", nil, time.Hour, timer.MisfireOnce); err != timer.ErrUnknownKind {
+		t.Error("4", err)
+	}
+	timer.Stop(h)
+	timer.StopPersistent("cron")
+	records, _ = timer.NewFileStore(path).Load()
+	if len(records) != 0 {
+		t.Error("5", records)
+	}
+}
+
+func TestRestorePaused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timer.json")
+	if err := timer.Restore(timer.NewFileStore(path)); err != nil {
+		t.Fatal(err)
+	}
+	fired := make(chan struct{}, 1)
+	timer.RegisterPersistent("paused", basic.Root, timer.ActionWrapper(func(h timer.Handle, ud interface{}) {
+		fired <- struct{}{}
+	}))
+	h, err := timer.NewPersistentTimer("paused", "paused", nil, time.Millisecond*50, timer.MisfireOnce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !timer.Pause(h) {
+		t.Fatal("1")
+	}
+
+	// 模拟进程重启，暂停中的定时器恢复后仍然是暂停的，不会执行
+	timer.StopAll()
+	if err = timer.Restore(timer.NewFileStore(path)); err != nil {
+		t.Fatal(err)
+	}
+	h, ok := timer.GetPersistent("paused")
+	if !ok {
+		t.Fatal("2")
+	}
+	select {
+	case <-fired:
+		t.Fatal("3 paused timer fired")
+	case <-time.After(time.Millisecond * 100):
+	}
+	if d, ok := timer.Remaining(h); !ok || d <= 0 || d > time.Millisecond*50 {
+		t.Error("4", d, ok)
+	}
+
+	// 恢复后继续计算剩余的时长
+	if !timer.Resume(h) {
+		t.Fatal("5")
+	}
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Error("6 timeout")
+	}
+}
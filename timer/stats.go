@@ -0,0 +1,171 @@
+package timer
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skeletongo/core/utils"
+)
+
+// LateThreshold 执行时间晚于计划时间超过这个时长时记为延迟执行
+var LateThreshold = 50 * time.Millisecond
+
+// Stats 定时器统计
+type Stats struct {
+	Active    int             // 没有结束的定时器数量
+	Created   uint64          // 创建的定时器数量
+	Fired     uint64          // 计时结束发送到执行节点的次数
+	Cancelled uint64          // 被终止的次数，包括执行节点关闭时自动终止
+	Late      uint64          // 执行时间晚于计划时间超过 LateThreshold 的次数
+	Lateness  utils.Histogram // 执行时间晚于计划时间的时长分布
+}
+
+var stats = struct {
+	created   uint64
+	fired     uint64
+	cancelled uint64
+	sync.Mutex
+	late     uint64
+	lateness utils.Histogram
+}{}
+
+// onLate 记录执行时间晚于计划时间的时长，在执行节点中调用
+func onLate(due time.Time) {
+	if due.IsZero() {
+		return
+	}
+	d := time.Since(due)
+	if d < 0 {
+		d = 0
+	}
+	stats.Lock()
+	stats.lateness.Add(d)
+	if d > LateThreshold {
+		stats.late++
+	}
+	stats.Unlock()
+}
+
+// GetStats 获取定时器统计
+func GetStats() *Stats {
+	ret := &Stats{
+		Created:   atomic.LoadUint64(&stats.created),
+		Fired:     atomic.LoadUint64(&stats.fired),
+		Cancelled: atomic.LoadUint64(&stats.cancelled),
+	}
+	handles.Range(func(key, value interface{}) bool {
+		ret.Active++
+		return true
+	})
+	stats.Lock()
+	ret.Late = stats.late
+	ret.Lateness = stats.lateness.Clone()
+	stats.Unlock()
+	return ret
+}
+
+// 定时器类型名称
+var kindNames = map[int]string{
+	kindTimer: "timer",
+	kindRate:  "ticker",
+	kindDelay: "delay_ticker",
+	kindCron:  "cron",
+}
+
+// Info 定时器信息
+type Info struct {
+	Handle  Handle
+	Owner   string    // 执行节点完整名称
+	Kind    string    // 类型 timer,ticker,delay_ticker,cron
+	Name    string    // 持久化定时器或 cron 选项的名称
+	Site    string    // 创建位置，文件名:行号，需要通过 SetTraceSite 开启
+	Created time.Time // 创建时间
+	Next    time.Time // 下次执行时间，暂停中或等待固定间隔定时方法执行结束时为零值
+	Paused  bool      // 是否暂停中
+}
+
+// traceSite 是否记录定时器的创建位置
+var traceSite int32
+
+// SetTraceSite 开启或关闭记录定时器的创建位置，用于 List 调试
+// 记录创建位置需要获取调用栈，开销比较大，默认关闭；只对之后创建的定时器有效
+func SetTraceSite(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&traceSite, v)
+}
+
+// callers 获取创建位置的调用栈，解析在 List 时进行，没有开启时返回零值
+func callers() [8]uintptr {
+	var pcs [8]uintptr
+	if atomic.LoadInt32(&traceSite) == 0 {
+		return pcs
+	}
+	runtime.Callers(3, pcs[:])
+	return pcs
+}
+
+// site 跳过 timer 包内的调用，返回第一个调用方的位置
+func site(pcs [8]uintptr) string {
+	n := 0
+	for n < len(pcs) && pcs[n] != 0 {
+		n++
+	}
+	if n == 0 {
+		return ""
+	}
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, "github.com/skeletongo/core/timer.") {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// info 定时器信息，需要加锁
+func (e *entry) info() *Info {
+	ret := &Info{
+		Handle:  e.h,
+		Kind:    kindNames[e.kind],
+		Site:    site(e.pcs),
+		Created: e.created,
+		Next:    e.next,
+		Paused:  e.paused,
+	}
+	if e.o != nil {
+		ret.Owner = e.o.FullName()
+	}
+	if e.p != nil {
+		ret.Name = e.p.name
+	} else if e.opt != nil {
+		ret.Name = e.opt.Name
+	}
+	return ret
+}
+
+// List 获取所有没有结束的定时器信息，按创建顺序排序
+func List() []*Info {
+	var ret []*Info
+	handles.Range(func(key, value interface{}) bool {
+		e := value.(*entry)
+		e.Lock()
+		ret = append(ret, e.info())
+		e.Unlock()
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Handle < ret[j].Handle
+	})
+	return ret
+}
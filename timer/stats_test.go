@@ -0,0 +1,53 @@
+package timer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/timer"
+)
+
+func TestList(t *testing.T) {
+	before := timer.GetStats()
+	a := timer.ActionWrapper(func(h timer.Handle, ud interface{}) {})
+	timer.SetTraceSite(true)
+	h1 := timer.NewTimer(basic.Root, a, nil, time.Hour)
+	timer.SetTraceSite(false)
+	h2, _ := timer.NewCronWithOptions(basic.Root, "0 0 0 * * *", func() {}, &timer.CronOptions{Name: "daily"})
+	h3 := timer.NewTimer(basic.Root, a, nil, time.Millisecond)
+	defer timer.Stop(h1)
+	defer timer.Stop(h2)
+
+	var i1, i2 *timer.Info
+	for _, v := range timer.List() {
+		switch v.Handle {
+		case h1:
+			i1 = v
+		case h2:
+			i2 = v
+		}
+	}
+	if i1 == nil || i1.Kind != "timer" || i1.Owner != "/root" || !strings.Contains(i1.Site, "stats_test.go") {
+		t.Fatal("1", i1)
+	}
+	if d := i1.Next.Sub(i1.Created); d < time.Hour || d > time.Hour+time.Millisecond {
+		t.Error("2", i1.Next, i1.Created)
+	}
+	// 没有开启时不记录创建位置
+	if i2 == nil || i2.Kind != "cron" || i2.Name != "daily" || i2.Site != "" {
+		t.Fatal("3", i2)
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	timer.Stop(h3)
+	timer.Stop(h1)
+	after := timer.GetStats()
+	if after.Created-before.Created != 3 || after.Fired-before.Fired < 1 || after.Cancelled-before.Cancelled != 1 {
+		t.Error("4", before, after)
+	}
+	if after.Lateness.Count <= before.Lateness.Count {
+		t.Error("5", after.Lateness)
+	}
+}
@@ -0,0 +1,149 @@
+package timer_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/timer"
+)
+
+func TestNewTicker(t *testing.T) {
+	var n int32
+	h := timer.NewTicker(basic.Root, timer.ActionWrapper(func(h timer.Handle, ud interface{}) {
+		atomic.AddInt32(&n, 1)
+	}), nil, time.Millisecond*20)
+	time.Sleep(time.Millisecond * 110)
+	timer.Stop(h)
+	if v := atomic.LoadInt32(&n); v < 4 || v > 6 {
+		t.Error("1", v)
+	}
+	v := atomic.LoadInt32(&n)
+	time.Sleep(time.Millisecond * 50)
+	if atomic.LoadInt32(&n) != v {
+		t.Error("2 stopped ticker fired")
+	}
+	if _, ok := timer.Remaining(h); ok {
+		t.Error("3")
+	}
+}
+
+func TestNewDelayTicker(t *testing.T) {
+	var n int32
+	h := timer.NewDelayTicker(basic.Root, timer.ActionWrapper(func(h timer.Handle, ud interface{}) {
+		atomic.AddInt32(&n, 1)
+		// 执行时长计入间隔
+		time.Sleep(time.Millisecond * 20)
+	}), nil, time.Millisecond*20)
+	time.Sleep(time.Millisecond * 130)
+	timer.Stop(h)
+	if v := atomic.LoadInt32(&n); v < 2 || v > 4 {
+		t.Error(v)
+	}
+}
+
+func TestPause(t *testing.T) {
+	ch := make(chan time.Time, 1)
+	start := time.Now()
+	h := timer.NewTimer(basic.Root, timer.ActionWrapper(func(h timer.Handle, ud interface{}) {
+		ch <- time.Now()
+	}), nil, time.Millisecond*100)
+	defer timer.Stop(h)
+
+	time.Sleep(time.Millisecond * 40)
+	if !timer.Pause(h) || timer.Pause(h) {
+		t.Error("1")
+	}
+	if !timer.NextTime(h).IsZero() {
+		t.Error("2")
+	}
+	remain, ok := timer.Remaining(h)
+	if !ok || remain > time.Millisecond*60 || remain < time.Millisecond*30 {
+		t.Error("3", remain, ok)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if !timer.Resume(h) || timer.Resume(h) {
+		t.Error("4")
+	}
+	if next := timer.NextTime(h); next.Sub(time.Now()) > remain {
+		t.Error("5", next)
+	}
+	select {
+	case v := <-ch:
+		if c := v.Sub(start); c < time.Millisecond*200 {
+			t.Error("6", c)
+		}
+	case <-time.After(time.Second):
+		t.Error("7 timeout")
+	}
+}
+
+func TestReset(t *testing.T) {
+	ch := make(chan time.Time, 1)
+	start := time.Now()
+	h := timer.NewTimer(basic.Root, timer.ActionWrapper(func(h timer.Handle, ud interface{}) {
+		ch <- time.Now()
+	}), nil, time.Millisecond*50)
+	if !timer.Reset(h, time.Millisecond*150) {
+		t.Error("1")
+	}
+	select {
+	case v := <-ch:
+		if c := v.Sub(start); c < time.Millisecond*150 {
+			t.Error("2", c)
+		}
+	case <-time.After(time.Second):
+		t.Error("3 timeout")
+	}
+	if timer.Reset(h, time.Millisecond) {
+		t.Error("4 reset finished timer")
+	}
+
+	c, _ := timer.NewCron(basic.Root, "* * * * * *", func() {})
+	defer timer.Stop(c)
+	if timer.Reset(c, time.Second) {
+		t.Error("5 reset cron")
+	}
+}
+
+func TestStopQueued(t *testing.T) {
+	o := basic.NewObject(102, "stop", new(basic.Options), nil)
+	o.Run()
+	basic.Root.AddChild(o)
+	defer o.Close()
+
+	// 阻塞执行节点，定时器计时结束后在队列中等待执行
+	gate := make(chan struct{})
+	o.Send(basic.CommandWrapper(func(*basic.Object) error {
+		<-gate
+		return nil
+	}))
+	var n int32
+	h := timer.NewTimer(o, timer.ActionWrapper(func(h timer.Handle, ud interface{}) {
+		atomic.AddInt32(&n, 1)
+	}), nil, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 50)
+	if len(timer.ObjectTimers(o)) != 1 {
+		t.Error("1 fired timer not tracked")
+	}
+	timer.Stop(h)
+	close(gate)
+
+	time.Sleep(time.Millisecond * 50)
+	if atomic.LoadInt32(&n) != 0 {
+		t.Error("2 one-shot ran after Stop")
+	}
+	if len(timer.ObjectTimers(o)) != 0 || len(timer.List()) != 0 {
+		t.Error("3", timer.ObjectTimers(o))
+	}
+
+	// 执行结束后删除
+	h = timer.NewTimer(o, timer.ActionWrapper(func(h timer.Handle, ud interface{}) {
+		atomic.AddInt32(&n, 1)
+	}), nil, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 50)
+	if atomic.LoadInt32(&n) != 1 || len(timer.ObjectTimers(o)) != 0 {
+		t.Error("4", n, timer.ObjectTimers(o))
+	}
+}
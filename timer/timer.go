@@ -29,7 +29,7 @@ func SetObject(o *basic.Object) {
 	defaultObject = o
 }
 
-// handles 保存所有未超时的定时器
+// handles 保存所有没有结束的定时器，最后一次执行结束后才删除; key:Handle,value:*entry
 var handles = new(sync.Map)
 
 var i uint32
@@ -45,33 +45,331 @@ type Timer struct {
 	data interface{}
 }
 
-func newTimer(o *basic.Object, h Handle, a Action, data interface{}, interval time.Duration) *time.Timer {
+// 定时器类型
+const (
+	kindTimer = iota // 延时方法
+	kindRate         // 固定频率的循环定时方法
+	kindDelay        // 固定间隔的循环定时方法
+	kindCron         // cron 表达式的循环定时方法
+)
+
+// entry 定时器
+type entry struct {
+	sync.Mutex
+	Timer
+	o        *basic.Object
+	kind     int
+	interval time.Duration // 延时时长或循环间隔
+	expr     *CronExpr
+	next     time.Time     // 下次执行的时间，等待固定间隔定时方法执行结束时为零值
+	remain   time.Duration // 暂停时剩余的时长
+	stopper  Stopper
+	seq      uint64      // 每次重新计时加一，用来忽略已经过期的计时
+	p        *persist    // 持久化信息，不是持久化定时器时为nil
+	opt      *cronOption // cron 选项，没有时为nil
+	created  time.Time   // 创建时间
+	pcs      [8]uintptr  // 创建位置的调用栈
+	paused   bool
+	canceled bool // 被 Stop 终止
+	done     bool // 不会再计时，最后一次执行可能还在执行节点的队列中
+}
+
+func newEntry(o *basic.Object, kind int, a Action, data interface{}) *entry {
 	if o == nil {
 		o = defaultObject
 	}
-	e := &Timer{
-		a:    a,
-		h:    h,
-		data: data,
+	return &entry{
+		Timer: Timer{
+			a:    a,
+			h:    getHandle(),
+			data: data,
+		},
+		o:       o,
+		kind:    kind,
+		created: time.Now(),
+		pcs:     callers(),
 	}
-	t := time.AfterFunc(interval, func() {
-		handles.Delete(e.h)
-		SendTimer(o, e)
+}
+
+// schedule 延时 d 后执行，需要加锁
+func (e *entry) schedule(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	e.seq++
+	seq := e.seq
+	e.next = time.Now().Add(d)
+	e.stopper = backend.AfterFunc(d, func() {
+		e.fire(seq)
 	})
-	handles.Store(h, t)
-	return t
+}
+
+// unschedule 取消计时，需要加锁
+func (e *entry) unschedule() {
+	e.seq++
+	if e.stopper != nil {
+		e.stopper.Stop()
+		e.stopper = nil
+	}
+}
+
+// finish 定时器不会再计时，需要加锁
+// 最后一次执行已经发送到执行节点时，执行结束后再调用 release，在这之前还可以通过 Stop 取消
+func (e *entry) finish() {
+	e.done = true
+	e.next = time.Time{}
+}
+
+// release 删除定时器，之后不能再通过 id 获取
+func (e *entry) release() {
+	handles.Delete(e.h)
+	histories.Delete(e.h)
+	untrack(e)
+}
+
+// start 保存定时器并开始计时，执行节点已经开始关闭时直接终止
+func (e *entry) start(d time.Duration) Handle {
+	if !e.add() {
+		return e.h
+	}
+	e.Lock()
+	if !e.done {
+		e.schedule(d)
+	}
+	e.Unlock()
+	return e.h
+}
+
+// startPaused 启动暂停中的定时器，恢复后计时 remain
+func (e *entry) startPaused(remain time.Duration) Handle {
+	if !e.add() {
+		return e.h
+	}
+	e.Lock()
+	e.paused = true
+	e.remain = remain
+	e.Unlock()
+	return e.h
+}
+
+// add 记录定时器，执行节点已经关闭时终止定时器并返回false
+func (e *entry) add() bool {
+	atomic.AddUint64(&stats.created, 1)
+	handles.Store(e.h, e)
+	if !track(e) {
+		e.stop()
+		return false
+	}
+	return true
+}
+
+// fire 计时结束，计算下次执行时间，并发送到执行节点
+func (e *entry) fire(seq uint64) {
+	e.Lock()
+	if e.done || e.paused || seq != e.seq {
+		e.Unlock()
+		return
+	}
+	now := time.Now()
+	due := e.next
+	switch e.kind {
+	case kindTimer:
+		e.finish()
+	case kindRate:
+		// 按原来的节奏计算下次执行时间，落后超过一个间隔时跳过错过的执行
+		next := e.next.Add(e.interval)
+		if next.Before(now) {
+			next = next.Add((now.Sub(next)/e.interval + 1) * e.interval)
+		}
+		e.schedule(next.Sub(now))
+	case kindDelay:
+		// 执行结束后再开始计时
+		e.next = time.Time{}
+	case kindCron:
+		if next := e.expr.Next(now); next.IsZero() {
+			e.finish()
+		} else {
+			e.schedule(next.Sub(now) + e.jitter())
+		}
+	}
+	var r *Record
+	if e.p != nil && e.kind == kindCron && !e.done {
+		// 执行前保存下次执行时间
+		r = e.record()
+	}
+	e.Unlock()
+	if r != nil {
+		saveRecord(r)
+	}
+	if e.opt != nil && e.opt.skip(now) {
+		e.afterSkip()
+		return
+	}
+	atomic.AddUint64(&stats.fired, 1)
+	sendEntry(e.o, e, seq, due)
+}
+
+// run 在执行节点中执行
+func (e *entry) run(seq uint64) {
+	defer e.afterRun(seq)
+	if e.opt != nil {
+		e.opt.run(e)
+		return
+	}
+	e.a.OnTimer(e.h, e.data)
+}
+
+// jitter cron 定时方法随机推迟的时长
+func (e *entry) jitter() time.Duration {
+	if e.opt == nil {
+		return 0
+	}
+	return e.opt.jitter()
+}
+
+// afterRun 执行结束后的处理，在执行节点中调用
+func (e *entry) afterRun(seq uint64) {
+	if e.p != nil && e.kind != kindCron {
+		// 延时方法执行结束后删除保存的记录
+		e.p.remove(e)
+	}
+	e.Lock()
+	defer e.Unlock()
+	if e.done {
+		e.release()
+		return
+	}
+	if e.kind != kindDelay || e.paused || seq != e.seq {
+		return
+	}
+	e.schedule(e.interval)
+}
+
+// cancelRun 已经计入正在执行的本次执行被取消，在所有不执行的返回路径上调用
+func (e *entry) cancelRun() {
+	if e.opt != nil {
+		atomic.AddInt32(&e.opt.running, -1)
+	}
+	e.afterSkip()
+}
+
+// afterSkip 本次执行被跳过或取消，没有执行的最后一次也算结束
+func (e *entry) afterSkip() {
+	e.Lock()
+	defer e.Unlock()
+	if e.done {
+		e.release()
+	}
+}
+
+func (e *entry) isCanceled() bool {
+	e.Lock()
+	defer e.Unlock()
+	return e.canceled
+}
+
+func (e *entry) stop() {
+	e.Lock()
+	defer e.Unlock()
+	if !e.done {
+		atomic.AddUint64(&stats.cancelled, 1)
+	}
+	e.canceled = true
+	e.unschedule()
+	e.finish()
+	e.release()
+}
+
+func (e *entry) pause() bool {
+	e.Lock()
+	defer e.Unlock()
+	if e.done || e.paused {
+		return false
+	}
+	e.remain = e.remaining(time.Now())
+	if e.kind == kindDelay && e.next.IsZero() {
+		// 正在等待执行结束，恢复后重新等待一个间隔
+		e.remain = e.interval
+	}
+	e.paused = true
+	e.unschedule()
+	e.next = time.Time{}
+	return true
+}
+
+func (e *entry) resume() bool {
+	e.Lock()
+	defer e.Unlock()
+	if e.done || !e.paused {
+		return false
+	}
+	e.paused = false
+	if e.kind == kindCron {
+		now := time.Now()
+		next := e.expr.Next(now)
+		if next.IsZero() {
+			e.finish()
+			e.release()
+			return true
+		}
+		e.schedule(next.Sub(now) + e.jitter())
+		return true
+	}
+	e.schedule(e.remain)
+	return true
+}
+
+func (e *entry) reset(d time.Duration) bool {
+	e.Lock()
+	defer e.Unlock()
+	if e.done || e.kind == kindCron {
+		return false
+	}
+	if e.kind != kindTimer && d <= 0 {
+		return false
+	}
+	e.interval = d
+	if e.paused {
+		e.remain = d
+		return true
+	}
+	e.unschedule()
+	e.schedule(d)
+	return true
+}
+
+// remaining 距离下次执行的时长，需要加锁
+func (e *entry) remaining(now time.Time) time.Duration {
+	if e.paused {
+		return e.remain
+	}
+	if e.next.IsZero() {
+		return 0
+	}
+	if d := e.next.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func getEntry(h Handle) *entry {
+	v, ok := handles.Load(h)
+	if !ok {
+		return nil
+	}
+	return v.(*entry)
 }
 
 // NewTimer 创建延时方法
-// o 方法执行节点，为nil时在默认节点上执行
+// o 方法执行节点，为nil时在默认节点上执行；节点开始关闭时自动终止
 // a 方法实例
 // data 方法执行需要的数据
 // interval 延时时长
 // 返回延时方法的id,用来提前终止执行
 func NewTimer(o *basic.Object, a Action, data interface{}, interval time.Duration) Handle {
-	var h = getHandle()
-	newTimer(o, h, a, data, interval)
-	return h
+	e := newEntry(o, kindTimer, a, data)
+	e.interval = interval
+	return e.start(interval)
 }
 
 // AfterTimer 创建在默认节点上执行的延时方法
@@ -83,45 +381,43 @@ func AfterTimer(w ActionWrapper, data interface{}, interval time.Duration) Handl
 	return NewTimer(defaultObject, w, data, interval)
 }
 
-func newCron(o *basic.Object, h Handle, cronExpr *CronExpr, cb func()) *time.Timer {
-	now := time.Now()
-	nextTime := cronExpr.Next(now)
-	if nextTime.IsZero() {
-		return nil
+func newTicker(o *basic.Object, kind int, a Action, data interface{}, interval time.Duration) Handle {
+	if interval <= 0 {
+		panic("non-positive interval for ticker")
 	}
+	e := newEntry(o, kind, a, data)
+	e.interval = interval
+	return e.start(interval)
+}
 
-	// callback
-	var t *time.Timer
-	var _cb ActionWrapper
-	_cb = func(h Handle, ud interface{}) {
-		defer cb()
-
-		now := time.Now()
-		nextTime := cronExpr.Next(now)
-		if nextTime.IsZero() {
-			return
-		}
-		t = newTimer(o, h, _cb, nil, nextTime.Sub(now))
-	}
+// NewTicker 创建固定频率的循环定时方法，每隔 interval 执行一次，不受方法执行时长的影响
+// 执行落后超过一个间隔时跳过错过的执行
+// o 方法执行节点，为nil时在默认节点上执行；节点开始关闭时自动终止
+// a 方法实例
+// data 方法执行需要的数据
+// interval 循环间隔，需要大于0
+// 返回定时方法的id,用来终止执行
+func NewTicker(o *basic.Object, a Action, data interface{}, interval time.Duration) Handle {
+	return newTicker(o, kindRate, a, data, interval)
+}
 
-	t = newTimer(o, h, _cb, nil, nextTime.Sub(now))
-	return t
+// NewDelayTicker 创建固定间隔的循环定时方法，上一次在执行节点中执行结束后，等待 interval 再执行下一次
+// o 方法执行节点，为nil时在默认节点上执行；节点开始关闭时自动终止
+// a 方法实例
+// data 方法执行需要的数据
+// interval 循环间隔，需要大于0
+// 返回定时方法的id,用来终止执行
+func NewDelayTicker(o *basic.Object, a Action, data interface{}, interval time.Duration) Handle {
+	return newTicker(o, kindDelay, a, data, interval)
 }
 
 // NewCron 创建循环定时方法
-// o 方法执行节点，为nil时在默认节点上执行
+// o 方法执行节点，为nil时在默认节点上执行；节点开始关闭时自动终止
 // expr 定时执行规则
 // f 定时执行的方法
 // 返回延时方法的id,用来提前终止执行,和expr配置错误
 func NewCron(o *basic.Object, expr string, f func()) (Handle, error) {
-	s, err := NewCronExpr(expr)
-	if err != nil {
-		return 0, err
-	}
-	var h = getHandle()
-	t := newCron(o, h, s, f)
-	handles.Store(h, t)
-	return h, nil
+	return NewCronWithOptions(o, expr, f, nil)
 }
 
 // StartCron
@@ -132,21 +428,89 @@ func StartCron(expr string, f func()) (Handle, error) {
 	return NewCron(defaultObject, expr, f)
 }
 
-// Stop 停止延时方法执行
+// Stop 停止延时方法执行，已经发送到执行节点还没有执行的也不再执行
+// 持久化定时器同时删除保存的记录
 func Stop(h Handle) {
-	v, ok := handles.Load(h)
-	if !ok {
-		return
+	if e := getEntry(h); e != nil {
+		e.stop()
+		if e.p != nil {
+			e.p.remove(e)
+		}
 	}
-	handles.Delete(h)
-	v.(*time.Timer).Stop()
 }
 
-// StopAll 停止所有延时方法的执行
+// StopAll 停止所有延时方法的执行，持久化定时器保存的记录不会删除，下次启动时恢复
 func StopAll() {
 	handles.Range(func(key, value interface{}) bool {
-		value.(*time.Timer).Stop()
+		value.(*entry).stop()
 		return true
 	})
-	handles = new(sync.Map)
+}
+
+// Reset 修改延时时长或循环间隔，并重新开始计时
+// 暂停中的定时器恢复后按新的时长计时；cron 定时方法不支持，返回false
+// 定时器不存在或已经结束时返回false
+func Reset(h Handle, d time.Duration) bool {
+	e := getEntry(h)
+	if e == nil || !e.reset(d) {
+		return false
+	}
+	if e.p != nil {
+		e.p.save(e)
+	}
+	return true
+}
+
+// Pause 暂停计时，恢复后继续计算剩余的时长
+// 持久化定时器同时保存暂停状态和剩余的时长，重启后恢复为暂停中
+// 定时器不存在、已经结束或已经暂停时返回false
+func Pause(h Handle) bool {
+	e := getEntry(h)
+	if e == nil || !e.pause() {
+		return false
+	}
+	if e.p != nil {
+		e.p.save(e)
+	}
+	return true
+}
+
+// Resume 恢复暂停的定时器，cron 定时方法从当前时间重新计算下次执行时间
+// 定时器不存在、已经结束或没有暂停时返回false
+func Resume(h Handle) bool {
+	e := getEntry(h)
+	if e == nil || !e.resume() {
+		return false
+	}
+	if e.p != nil {
+		e.p.save(e)
+	}
+	return true
+}
+
+// Remaining 距离下次执行的剩余时长，暂停时为暂停时剩余的时长
+// 定时器不存在或已经结束时第二个返回值为false
+func Remaining(h Handle) (time.Duration, bool) {
+	e := getEntry(h)
+	if e == nil {
+		return 0, false
+	}
+	e.Lock()
+	defer e.Unlock()
+	if e.done {
+		return 0, false
+	}
+	return e.remaining(time.Now()), true
+}
+
+// NextTime 下次执行的时间
+// 定时器不存在、已经结束、暂停中或正在等待固定间隔定时方法执行结束时返回零值
+func NextTime(h Handle) time.Time {
+	e := getEntry(h)
+	if e == nil {
+		return time.Time{}
+	}
+	e.Lock()
+	defer e.Unlock()
+	return e.next
 }
@@ -0,0 +1,184 @@
+package timer
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	wheelBits   = 6
+	wheelSize   = 1 << wheelBits // 每层的槽数量
+	wheelMask   = wheelSize - 1
+	wheelLevels = 5 // 层数，最长延时为 tick * 64^5，超出时在最高层循环等待
+)
+
+// wheelEntry 时间轮中的一个定时器
+type wheelEntry struct {
+	w      *Wheel
+	expire uint64 // 到期的刻度
+	f      func()
+	// 所在槽的双向链表，不在时间轮中时 slot 为nil
+	slot       *wheelEntry
+	prev, next *wheelEntry
+}
+
+// Stop 取消执行
+func (e *wheelEntry) Stop() bool {
+	e.w.Lock()
+	defer e.w.Unlock()
+	if e.slot == nil {
+		return false
+	}
+	e.remove()
+	return true
+}
+
+func (e *wheelEntry) remove() {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next, e.slot = nil, nil, nil
+}
+
+// Wheel 分层时间轮，所有定时器由一个协程按固定刻度驱动
+// 添加和取消定时器的时间复杂度为 O(1)，精度为一个刻度，到期时间向上取整到刻度
+type Wheel struct {
+	sync.Mutex
+	tick   time.Duration
+	start  time.Time
+	now    uint64 // 已经处理的刻度
+	slots  [wheelLevels][wheelSize]wheelEntry
+	ticker *time.Ticker
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// NewWheel 创建并启动时间轮
+// tick 刻度时长，也是定时器的精度，小于等于0时为10毫秒
+func NewWheel(tick time.Duration) *Wheel {
+	if tick <= 0 {
+		tick = time.Millisecond * 10
+	}
+	w := &Wheel{
+		tick:   tick,
+		start:  time.Now(),
+		ticker: time.NewTicker(tick),
+		stop:   make(chan struct{}),
+	}
+	for i := range w.slots {
+		for k := range w.slots[i] {
+			s := &w.slots[i][k]
+			s.prev, s.next = s, s
+		}
+	}
+	go w.run()
+	return w
+}
+
+// AfterFunc 延时 d 后在时间轮的协程中执行 f
+// f 需要尽快返回，否则会推迟其它定时器的执行
+func (w *Wheel) AfterFunc(d time.Duration, f func()) Stopper {
+	e := &wheelEntry{w: w, f: f}
+	w.Lock()
+	// 向上取整到刻度
+	e.expire = uint64((time.Since(w.start) + d + w.tick - 1) / w.tick)
+	if e.expire <= w.now {
+		// 已经到期，在下一个刻度执行
+		e.expire = w.now + 1
+	}
+	w.add(e)
+	w.Unlock()
+	return e
+}
+
+// add 根据到期刻度放入对应层的槽中，到期刻度不能小于当前刻度
+func (w *Wheel) add(e *wheelEntry) {
+	expire := e.expire
+	delta := expire - w.now
+	level := 0
+	for ; level < wheelLevels-1; level++ {
+		if delta < 1<<(wheelBits*(level+1)) {
+			break
+		}
+	}
+	if max := uint64(1)<<(wheelBits*wheelLevels) - 1; delta > max {
+		// 超出最长延时，先放到最高层最远的槽中，之后重新计算
+		expire = w.now + max
+	}
+	s := &w.slots[level][(expire>>(wheelBits*level))&wheelMask]
+	e.slot = s
+	e.prev = s.prev
+	e.next = s
+	s.prev.next = e
+	s.prev = e
+}
+
+// cascade 把高层槽中的定时器重新放入低层
+func (w *Wheel) cascade(level int) {
+	idx := (w.now >> (wheelBits * level)) & wheelMask
+	s := &w.slots[level][idx]
+	for e := s.next; e != s; e = s.next {
+		e.remove()
+		w.add(e)
+	}
+	if idx == 0 && level < wheelLevels-1 {
+		w.cascade(level + 1)
+	}
+}
+
+// advance 处理到刻度 target，返回到期的定时器
+func (w *Wheel) advance(target uint64) []*wheelEntry {
+	var ready []*wheelEntry
+	w.Lock()
+	defer w.Unlock()
+	for w.now < target {
+		w.now++
+		idx := w.now & wheelMask
+		if idx == 0 {
+			w.cascade(1)
+		}
+		s := &w.slots[0][idx]
+		for e := s.next; e != s; e = s.next {
+			e.remove()
+			ready = append(ready, e)
+		}
+	}
+	return ready
+}
+
+func (w *Wheel) run() {
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.ticker.C:
+			target := uint64(time.Since(w.start) / w.tick)
+			for _, e := range w.advance(target) {
+				e.f()
+			}
+		}
+	}
+}
+
+// Len 时间轮中的定时器数量
+func (w *Wheel) Len() int {
+	w.Lock()
+	defer w.Unlock()
+	n := 0
+	for i := range w.slots {
+		for k := range w.slots[i] {
+			s := &w.slots[i][k]
+			for e := s.next; e != s; e = e.next {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Close 停止时间轮，没有执行的定时器不再执行
+func (w *Wheel) Close() {
+	w.once.Do(func() {
+		w.ticker.Stop()
+		close(w.stop)
+	})
+}
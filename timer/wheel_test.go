@@ -0,0 +1,110 @@
+package timer_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skeletongo/core/basic"
+	"github.com/skeletongo/core/timer"
+)
+
+func TestWheel(t *testing.T) {
+	w := timer.NewWheel(time.Millisecond * 5)
+	defer w.Close()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	var late int32
+	for _, d := range []time.Duration{0, time.Millisecond * 3, time.Millisecond * 20, time.Millisecond * 330, time.Millisecond * 700} {
+		d := d
+		wg.Add(1)
+		w.AfterFunc(d, func() {
+			if c := time.Since(start); c < d || c > d+time.Millisecond*100 {
+				t.Error("1", d, c)
+				atomic.AddInt32(&late, 1)
+			}
+			wg.Done()
+		})
+	}
+	s := w.AfterFunc(time.Millisecond*10, func() {
+		t.Error("2 stopped timer fired")
+	})
+	if !s.Stop() || s.Stop() {
+		t.Error("3")
+	}
+	wg.Wait()
+	if w.Len() != 0 {
+		t.Error("4", w.Len())
+	}
+}
+
+func TestSetBackend(t *testing.T) {
+	w := timer.NewWheel(time.Millisecond)
+	defer w.Close()
+	timer.SetBackend(w)
+	defer timer.SetBackend(nil)
+
+	ch := make(chan timer.Handle, 1)
+	h := timer.NewTimer(basic.Root, timer.ActionWrapper(func(h timer.Handle, ud interface{}) {
+		ch <- h
+	}), nil, time.Millisecond*10)
+	select {
+	case v := <-ch:
+		if v != h {
+			t.Error(v, h)
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout")
+	}
+}
+
+func benchmarkAddStop(b *testing.B, backend timer.Backend) {
+	f := func() {}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		backend.AfterFunc(time.Minute+time.Duration(i%1000)*time.Millisecond, f).Stop()
+	}
+}
+
+func BenchmarkRuntime_AddStop(b *testing.B) {
+	benchmarkAddStop(b, timer.Runtime)
+}
+
+func BenchmarkWheel_AddStop(b *testing.B) {
+	w := timer.NewWheel(time.Millisecond * 10)
+	defer w.Close()
+	benchmarkAddStop(b, w)
+}
+
+// benchmarkMany 同时存在大量定时器时添加和取消
+func benchmarkMany(b *testing.B, backend timer.Backend) {
+	f := func() {}
+	var all []timer.Stopper
+	for i := 0; i < 100000; i++ {
+		all = append(all, backend.AfterFunc(time.Minute+time.Duration(i)*time.Millisecond, f))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := i % len(all)
+		all[k].Stop()
+		all[k] = backend.AfterFunc(time.Minute+time.Duration(k)*time.Millisecond, f)
+	}
+	b.StopTimer()
+	for _, v := range all {
+		v.Stop()
+	}
+}
+
+func BenchmarkRuntime_Many(b *testing.B) {
+	benchmarkMany(b, timer.Runtime)
+}
+
+func BenchmarkWheel_Many(b *testing.B) {
+	w := timer.NewWheel(time.Millisecond * 10)
+	defer w.Close()
+	benchmarkMany(b, w)
+}
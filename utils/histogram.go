@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"time"
+)
+
+// DefaultBuckets 时长分布的默认区间上限
+var DefaultBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Histogram 时长分布，零值使用 DefaultBuckets，不是协程安全的
+type Histogram struct {
+	Buckets []time.Duration // 区间上限
+	Counts  []uint64        // 每个区间的数量，最后一个是超出所有上限的数量
+	Count   uint64          // 总数
+	Sum     time.Duration   // 总时长
+	Max     time.Duration   // 最大时长
+}
+
+// Add 记录一个时长
+func (h *Histogram) Add(d time.Duration) {
+	if h.Counts == nil {
+		if h.Buckets == nil {
+			h.Buckets = DefaultBuckets
+		}
+		h.Counts = make([]uint64, len(h.Buckets)+1)
+	}
+	i := 0
+	for ; i < len(h.Buckets); i++ {
+		if d <= h.Buckets[i] {
+			break
+		}
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+// Avg 平均时长
+func (h *Histogram) Avg() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Clone 复制，不和原来的共享 Counts
+func (h *Histogram) Clone() Histogram {
+	c := *h
+	c.Counts = append([]uint64(nil), h.Counts...)
+	return c
+}
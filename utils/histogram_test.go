@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	var h Histogram
+	for _, d := range []time.Duration{0, time.Millisecond, 3 * time.Millisecond, time.Minute} {
+		h.Add(d)
+	}
+	if h.Count != 4 || h.Max != time.Minute || h.Counts[0] != 2 || h.Counts[1] != 1 || h.Counts[len(DefaultBuckets)] != 1 {
+		t.Error("1", h)
+	}
+	if h.Avg() != h.Sum/4 {
+		t.Error("2", h.Avg())
+	}
+	c := h.Clone()
+	c.Add(0)
+	if h.Counts[0] != 2 {
+		t.Error("3 clone shares counts")
+	}
+}